@@ -15,8 +15,10 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
@@ -27,6 +29,18 @@ import (
 type appendLogger struct {
 	logger Logger
 	target Target
+
+	bound
+}
+
+// bound records what For already added to the logger, so that it isn't added
+// again by the For of a ctx which is derived from the same one.
+type bound struct {
+	// span is the span or the TraceContext which the logger is bound to.
+	span interface{}
+
+	// fields are the fields of ContextWithFields which the logger has.
+	fields *fieldsNode
 }
 
 func (l appendLogger) Sync() error {
@@ -138,7 +152,7 @@ func (l appendLogger) With(fields ...Field) Logger {
 	return appendLogger{logger: l.logger.With(fields...), target: withFields{
 		fields: fields,
 		out:    l.target,
-	}}
+	}, bound: l.bound}
 }
 
 // With creates a child logger, and optionally adds some context fields to that logger.
@@ -146,16 +160,44 @@ func (l appendLogger) WithTargets(targets ...Target) Logger {
 	if len(targets) == 0 {
 		return l
 	}
-	return appendLogger{logger: l.logger, target: ConcatTargets(l.target, targets...)}
+	return appendLogger{logger: l.logger, target: ConcatTargets(l.target, targets...), bound: l.bound}
 }
 
 func (l appendLogger) Named(name string) Logger {
-	return appendLogger{logger: l.logger.Named(name), target: l.target}
+	return appendLogger{logger: l.logger.Named(name), target: l.target, bound: l.bound}
+}
+
+func (l appendLogger) Every(interval time.Duration) Logger {
+	return every(l, interval)
+}
+
+func (l appendLogger) EveryN(n int) Logger {
+	return everyN(l, n)
+}
+
+func (l appendLogger) Once() Logger {
+	return once(l)
+}
+
+func (l appendLogger) DebugContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Debug(msg, fields...)
+}
+
+func (l appendLogger) InfoContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Info(msg, fields...)
+}
+
+func (l appendLogger) WarnContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Warn(msg, fields...)
+}
+
+func (l appendLogger) ErrorContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Error(msg, fields...)
 }
 
 func (l appendLogger) AddCallerSkip(level int) Logger {
 	logger := l.logger.AddCallerSkip(level)
-	return appendLogger{logger: logger, target: l.target}
+	return appendLogger{logger: logger, target: l.target, bound: l.bound}
 }
 
 func (l appendLogger) Unwrap() *zap.Logger {
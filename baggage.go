@@ -0,0 +1,77 @@
+package log
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// spanBaggageFields returns the baggage items of the opentracing span which
+// are allowed by BaggageFields.
+func spanBaggageFields(span opentracing.Span) []Field {
+	var fields []Field
+	for _, key := range BaggageFields {
+		if value := span.BaggageItem(key); value != "" {
+			fields = append(fields, String(key, value))
+		}
+	}
+	return fields
+}
+
+// otelBaggageFields returns the OpenTelemetry baggage members in the ctx
+// which are allowed by BaggageFields.
+func otelBaggageFields(ctx context.Context) []Field {
+	if len(BaggageFields) == 0 {
+		return nil
+	}
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+
+	var fields []Field
+	for _, key := range BaggageFields {
+		if member := bag.Member(key); member.Key() != "" {
+			fields = append(fields, String(key, member.Value()))
+		}
+	}
+	return fields
+}
+
+// BaggageFieldsFromContext returns the baggage items of the opentracing span,
+// the OpenTelemetry baggage or the W3C TraceContext in the ctx, which are
+// allowed by BaggageFields.
+func BaggageFieldsFromContext(ctx context.Context) []Field {
+	if len(BaggageFields) == 0 {
+		return nil
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return spanBaggageFields(span)
+	}
+	if fields := otelBaggageFields(ctx); len(fields) > 0 {
+		return fields
+	}
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		var fields []Field
+		for _, key := range BaggageFields {
+			if value, ok := tc.Baggage[key]; ok {
+				fields = append(fields, String(key, value))
+			}
+		}
+		return fields
+	}
+	return nil
+}
+
+// withOTelBaggage adds the allowed OpenTelemetry baggage members in the ctx
+// to logger, and lowers its level if the baggage carries the debug flag.
+func withOTelBaggage(logger Logger, ctx context.Context) Logger {
+	if fields := otelBaggageFields(ctx); len(fields) > 0 {
+		logger = logger.With(fields...)
+	}
+	if TraceDebug && isOTelBaggageDebug(ctx) {
+		logger = withMinLevel(logger, TraceDebugLevel)
+	}
+	return logger
+}
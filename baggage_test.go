@@ -0,0 +1,44 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBaggageFields(t *testing.T) {
+	BaggageFields = []string{"tenant", "user"}
+	defer func() { BaggageFields = nil }()
+
+	span := mocktracer.New().StartSpan("a")
+	span.SetBaggageItem("tenant", "acme")
+	span.SetBaggageItem("secret", "x")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	For(ctx, logger).Info("a")
+	SpanFromContext(ctx, logger).Info("b")
+	logger.ToSlogger().InfoCtx(ctx, "c")
+
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if fields["tenant"] != "acme" {
+			t.Error(entry.Message, fields)
+		}
+		if _, ok := fields["secret"]; ok {
+			t.Error(entry.Message, fields)
+		}
+		if _, ok := fields["user"]; ok {
+			t.Error(entry.Message, fields)
+		}
+	}
+	if logs.Len() != 3 {
+		t.Error(logs.Len())
+	}
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContextMethods(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core, zap.AddCaller()))
+
+	span := mocktracer.New().StartSpan("a")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	ctx = ContextWithFields(ctx, String("user_id", "2"))
+	ctx = ContextWithRequestID(ctx, "1")
+
+	logger.DebugContext(ctx, "debug")
+	logger.InfoContext(ctx, "info")
+	logger.WarnContext(ctx, "warn")
+	logger.ErrorContext(ctx, "error")
+	logger.InfoContext(context.Background(), "background")
+	Empty().InfoContext(ctx, "empty")
+
+	entries := logs.All()
+	if len(entries) != 5 {
+		t.Fatal(entries)
+	}
+	for _, entry := range entries[:4] {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "1" || fields["user_id"] != "2" || fields["trace_id"] == nil {
+			t.Error(entry.Message, fields)
+		}
+		if entry.Caller.Function != "github.com/runner-mei/log.TestContextMethods" {
+			t.Error(entry.Caller.Function)
+		}
+	}
+	if len(entries[4].Context) != 0 {
+		t.Error(entries[4].Context)
+	}
+	if records := span.(*mocktracer.MockSpan).Logs(); len(records) != 4 {
+		t.Error(len(records))
+	}
+}
+
+func TestContextMethodsDerived(t *testing.T) {
+	for _, traced := range []bool{false, true} {
+		tracer := mocktracer.New()
+		if traced {
+			opentracing.SetGlobalTracer(tracer)
+		}
+
+		core, logs := observer.New(DebugLevel)
+		logger := NewLogger(zap.New(core))
+		handler := NewHTTPMiddleware(logger, HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			derived := For(ctx)
+			derived.InfoContext(ctx, "derived")
+			logger.InfoContext(ctx, "base")
+
+			ctx = ContextWithFields(ctx, String("user_id", "42"))
+			derived.InfoContext(ctx, "user")
+		}))
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+		entries := logs.All()
+		if len(entries) != 4 {
+			t.Fatal(traced, entries)
+		}
+		for _, entry := range entries {
+			fields := entry.ContextMap()
+			if len(entry.Context) != len(fields) || fields["request_id"] == nil || fields["span_id"] == nil {
+				t.Error(traced, entry.Message, entry.Context)
+			}
+			if entry.Message == "user" && fields["user_id"] != "42" {
+				t.Error(traced, entry.Message, fields)
+			}
+		}
+		if traced {
+			if records := tracer.FinishedSpans()[0].Logs(); len(records) != 4 {
+				t.Error(records)
+			}
+		}
+	}
+	// the request id which isn't a field of the ctx is pulled at call time
+	core, logs := observer.New(DebugLevel)
+	ctx := opentracing.ContextWithSpan(context.Background(), mocktracer.New().StartSpan("a"))
+	derived := For(ctx, NewLogger(zap.New(core)))
+	derived.InfoContext(ContextWithRequestID(ctx, "1"), "request")
+	if fields := logs.All()[0].ContextMap(); fields["request_id"] != "1" {
+		t.Error(fields)
+	}
+}
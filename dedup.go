@@ -0,0 +1,225 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultDedupWindow is the default window in which repeated entries are suppressed.
+const DefaultDedupWindow = 10 * time.Second
+
+// DedupConfig configures Deduper.
+type DedupConfig struct {
+	// Window is the time after the first entry in which its repetitions are
+	// suppressed, default is DefaultDedupWindow.
+	Window time.Duration
+
+	// Fields are the names of fields whose values are also compared, by
+	// default only level, message and caller are compared.
+	Fields []string
+}
+
+// Deduper suppresses repeated entries. The first entry is passed through,
+// its repetitions within the window are dropped, and a summary such as
+// "message repeated 1234 times in 10s" is emitted when the window closes or a
+// different entry arrives.
+type Deduper struct {
+	window time.Duration
+	fields []string
+
+	mu   sync.Mutex
+	last *repeated
+}
+
+type repeated struct {
+	key    string
+	first  time.Time
+	count  int
+	timer  *time.Timer
+	report func(count int, elapsed time.Duration)
+}
+
+// NewDeduper creates a Deduper with the given config.
+func NewDeduper(cfg DedupConfig) *Deduper {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultDedupWindow
+	}
+	return &Deduper{
+		window: cfg.Window,
+		fields: cfg.Fields,
+	}
+}
+
+func (d *Deduper) key(level Level, msg, caller string, fields []Field) string {
+	var sb strings.Builder
+	sb.WriteString(level.String())
+	sb.WriteByte(0)
+	sb.WriteString(caller)
+	sb.WriteByte(0)
+	sb.WriteString(msg)
+	for _, name := range d.fields {
+		for idx := len(fields) - 1; idx >= 0; idx-- {
+			if fields[idx].Key == name {
+				sb.WriteByte(0)
+				sb.WriteString(name)
+				sb.WriteByte('=')
+				sb.WriteString(fieldString(fields[idx]))
+				break
+			}
+		}
+	}
+	return sb.String()
+}
+
+// allow reports whether the entry with the key should be written, report is
+// called with the number of suppressed repetitions once they are summarized.
+func (d *Deduper) allow(key string, report func(count int, elapsed time.Duration)) bool {
+	now := time.Now()
+
+	d.mu.Lock()
+	if last := d.last; last != nil && last.key == key && now.Sub(last.first) < d.window {
+		last.count++
+		if last.count == 1 {
+			last.timer = time.AfterFunc(d.window-now.Sub(last.first), func() {
+				d.expire(last)
+			})
+		}
+		d.mu.Unlock()
+		return false
+	}
+
+	prev := d.last
+	prevCount := d.take(prev)
+	d.last = &repeated{key: key, first: now, report: report}
+	d.mu.Unlock()
+
+	if prevCount > 0 {
+		prev.report(prevCount, now.Sub(prev.first))
+	}
+	return true
+}
+
+// take resets the suppressed count of r, it must be called with d.mu held.
+func (d *Deduper) take(r *repeated) int {
+	if r == nil {
+		return 0
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	count := r.count
+	r.count = 0
+	return count
+}
+
+func (d *Deduper) expire(r *repeated) {
+	d.mu.Lock()
+	if d.last == r {
+		d.last = nil
+	}
+	count := d.take(r)
+	d.mu.Unlock()
+
+	if count > 0 {
+		r.report(count, time.Since(r.first))
+	}
+}
+
+// Flush emits the summary of the pending repetitions, if any.
+func (d *Deduper) Flush() {
+	d.mu.Lock()
+	last := d.last
+	d.last = nil
+	count := d.take(last)
+	d.mu.Unlock()
+
+	if count > 0 {
+		last.report(count, time.Since(last.first))
+	}
+}
+
+func repeatedMessage(count int, elapsed time.Duration) string {
+	if elapsed >= time.Second {
+		elapsed = elapsed.Round(time.Second)
+	} else {
+		elapsed = elapsed.Round(time.Millisecond)
+	}
+	return fmt.Sprintf("message repeated %d times in %s", count, elapsed)
+}
+
+// Target returns a Target which writes to out with the repetitions suppressed.
+func (d *Deduper) Target(out Target) Target {
+	return Callback(func(level Level, msg string, fields ...Field) {
+		if level >= PanicLevel {
+			out.LogFields(level, msg, fields...)
+			return
+		}
+
+		key := d.key(level, msg, "", fields)
+		if d.allow(key, func(count int, elapsed time.Duration) {
+			out.LogFields(level, repeatedMessage(count, elapsed), String("message", msg))
+		}) {
+			out.LogFields(level, msg, fields...)
+		}
+	})
+}
+
+// WrapCore wraps a zapcore.Core so that repeated entries are suppressed, it
+// can be used with zap.WrapCore.
+func (d *Deduper) WrapCore(core zapcore.Core) zapcore.Core {
+	return dedupCore{Core: core, dedup: d}
+}
+
+type dedupCore struct {
+	zapcore.Core
+	dedup *Deduper
+}
+
+func (c dedupCore) With(fields []Field) zapcore.Core {
+	return dedupCore{Core: c.Core.With(fields), dedup: c.dedup}
+}
+
+func (c dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write suppresses the repeated entry, the others are written through the
+// Check of the wrapped core, so that its sampling and level filtering apply.
+func (c dedupCore) Write(ent zapcore.Entry, fields []Field) error {
+	if ent.Level >= PanicLevel {
+		return checkWrite(c.Core, ent, fields)
+	}
+
+	key := c.dedup.key(ent.Level, ent.Message, ent.Caller.String(), fields)
+	if !c.dedup.allow(key, func(count int, elapsed time.Duration) {
+		summary := ent
+		summary.Time = time.Now()
+		summary.Message = repeatedMessage(count, elapsed)
+		summary.Stack = ""
+		checkWrite(c.Core, summary, []Field{String("message", ent.Message)})
+	}) {
+		return nil
+	}
+	return checkWrite(c.Core, ent, fields)
+}
+
+var checkWriteErrorOutput = zapcore.Lock(os.Stderr)
+
+// checkWrite writes the entry to the cores which core.Check selects, the
+// write errors are reported to stderr, like the ones of zap.Logger.
+func checkWrite(core zapcore.Core, ent zapcore.Entry, fields []Field) error {
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.ErrorOutput = checkWriteErrorOutput
+		ce.Write(fields...)
+	}
+	return nil
+}
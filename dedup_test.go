@@ -0,0 +1,86 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDeduperTarget(t *testing.T) {
+	var messages []string
+	out := Callback(func(level Level, msg string, fields ...Field) {
+		messages = append(messages, msg)
+	})
+
+	dedup := NewDeduper(DedupConfig{Window: time.Minute, Fields: []string{"id"}})
+	target := dedup.Target(out)
+	for i := 0; i < 5; i++ {
+		target.LogFields(InfoLevel, "a", Int("id", 1))
+	}
+	target.LogFields(InfoLevel, "a", Int("id", 2))
+	target.LogFields(InfoLevel, "b")
+	target.LogFields(InfoLevel, "b")
+	dedup.Flush()
+
+	if len(messages) != 5 {
+		t.Fatal(messages)
+	}
+	if messages[0] != "a" || messages[2] != "a" || messages[3] != "b" {
+		t.Error(messages)
+	}
+	if !strings.HasPrefix(messages[1], "message repeated 4 times in ") {
+		t.Error(messages[1])
+	}
+	if !strings.HasPrefix(messages[4], "message repeated 1 times in ") {
+		t.Error(messages[4])
+	}
+}
+
+func TestDeduperCoreWindow(t *testing.T) {
+	dedup := NewDeduper(DedupConfig{Window: 50 * time.Millisecond})
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(dedup.WrapCore(core)))
+
+	for i := 0; i < 3; i++ {
+		logger.Warn("a")
+	}
+	time.Sleep(200 * time.Millisecond)
+	logger.Warn("a")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 3 {
+		t.Fatal(entries)
+	}
+	if entries[0].Message != "a" || entries[2].Message != "a" {
+		t.Error(entries)
+	}
+	if !strings.HasPrefix(entries[1].Message, "message repeated 2 times in ") ||
+		entries[1].Level != WarnLevel ||
+		entries[1].ContextMap()["message"] != "a" {
+		t.Error(entries[1])
+	}
+}
+
+func TestDeduperCoreDelegatesCheck(t *testing.T) {
+	debugCore, debugLogs := observer.New(DebugLevel)
+	errorCore, errorLogs := observer.New(ErrorLevel)
+	dedup := NewDeduper(DedupConfig{Window: time.Minute, Fields: []string{"id"}})
+	core := zapcore.NewSampler(zapcore.NewTee(debugCore, errorCore), time.Hour, 1, 1000)
+	logger := NewLogger(zap.New(dedup.WrapCore(core)))
+
+	for i := 0; i < 10; i++ {
+		logger.Debug("a", Int("id", i))
+	}
+	logger.Error("b")
+
+	if n := debugLogs.FilterMessage("a").Len(); n != 1 {
+		t.Error("sampled debug entries are", n)
+	}
+	if errorLogs.Len() != 1 || errorLogs.All()[0].Message != "b" {
+		t.Error(errorLogs.AllUntimed())
+	}
+}
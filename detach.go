@@ -0,0 +1,64 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Detach returns a context which keeps all values of ctx, but is never
+// canceled and has no deadline, so that the async work started by a request
+// keeps its logger, span and fields after the request ends.
+func Detach(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	if _, ok := ctx.(detachedContext); ok {
+		return ctx
+	}
+	return detachedContext{parent: ctx}
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func (c detachedContext) String() string {
+	return "log.Detach"
+}
+
+var (
+	contextKeysLock sync.RWMutex
+	contextKeys     []interface{}
+)
+
+// RegisterContextKey adds the keys of the values which are copied by
+// CloneContext in addition to the logging values, such as the keys of the
+// request-scoped values of a library.
+func RegisterContextKey(keys ...interface{}) {
+	contextKeysLock.Lock()
+	contextKeys = append(contextKeys, keys...)
+	contextKeysLock.Unlock()
+}
+
+// copyContextKeys copies the values of the registered keys from src to dst.
+func copyContextKeys(src, dst context.Context) context.Context {
+	contextKeysLock.RLock()
+	keys := contextKeys
+	contextKeysLock.RUnlock()
+
+	for _, key := range keys {
+		if value := src.Value(key); value != nil {
+			dst = context.WithValue(dst, key, value)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testContextKey struct{}
+
+func TestCloneContext(t *testing.T) {
+	src, cancel := context.WithTimeout(context.Background(), time.Minute)
+	src = ContextWithFields(src, String("request_id", "1"))
+	src = ContextWithRequestID(src, "1")
+	src = context.WithValue(src, testContextKey{}, "value")
+	cancel()
+
+	detached := CloneContext(src)
+	if detached.Err() != nil || detached.Done() != nil {
+		t.Error("context isn't detached")
+	}
+	if _, ok := detached.Deadline(); ok {
+		t.Error("deadline is kept")
+	}
+	if detached.Value(testContextKey{}) != "value" || RequestIDFromContext(detached) != "1" ||
+		len(FieldsFromContext(detached)) != 1 {
+		t.Error("values are lost")
+	}
+
+	dst := CloneContext(src, context.Background())
+	if dst.Value(testContextKey{}) != nil {
+		t.Error("unregistered key is copied")
+	}
+	if RequestIDFromContext(dst) != "1" || len(FieldsFromContext(dst)) != 1 {
+		t.Error("logging values are lost")
+	}
+
+	RegisterContextKey(testContextKey{})
+	defer func() { contextKeys = nil }()
+	if dst := CloneContext(src, context.Background()); dst.Value(testContextKey{}) != "value" {
+		t.Error("registered key isn't copied")
+	}
+}
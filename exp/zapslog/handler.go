@@ -37,6 +37,8 @@ type Handler struct {
 	addCaller  bool
 	addStackAt slog.Level
 	callerSkip int
+
+	contextFields func(context.Context) []zapcore.Field
 }
 
 // NewHandler builds a [Handler] that writes to the supplied [zapcore.Core]
@@ -160,6 +162,9 @@ func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
 		fields = append(fields, convertAttrToField(attr))
 		return true
 	})
+	if h.contextFields != nil && ctx != nil {
+		fields = append(fields, h.contextFields(ctx)...)
+	}
 	ce.Write(fields...)
 	return nil
 }
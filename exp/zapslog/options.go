@@ -20,7 +20,12 @@
 
 package zapslog
 
-import "golang.org/x/exp/slog"
+import (
+	"context"
+
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/exp/slog"
+)
 
 // An Option configures a slog Handler.
 type Option interface {
@@ -68,3 +73,11 @@ func AddStacktraceAt(lvl slog.Level) Option {
 		log.addStackAt = lvl
 	})
 }
+
+// WithContextFields configures the Logger to add the fields returned by fn
+// for the context of every record.
+func WithContextFields(fn func(ctx context.Context) []zapcore.Field) Option {
+	return optionFunc(func(log *Handler) {
+		log.contextFields = fn
+	})
+}
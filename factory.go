@@ -16,60 +16,176 @@ package log
 
 import (
 	"context"
+	"reflect"
 
 	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Span returns a Logger which also echoes the entries into the opentracing
+// span and adds the trace id and span id fields and the baggage items allowed
+// by BaggageFields to them. If TraceDebug is enabled and the span is sampled
+// or carries the debug flag, the Logger also logs the entries enabled by
+// TraceDebugLevel.
 func Span(logger Logger, span opentracing.Span, enabledLevel ...Level) Logger {
 	if span == nil {
 		return logger
 	}
+	return spanLogger(logger, span, enabledLevel...)
+}
 
+// OTelSpan is Span for an OpenTelemetry span, the entries are echoed as its
+// events.
+func OTelSpan(logger Logger, span trace.Span, enabledLevel ...Level) Logger {
+	if span == nil {
+		return logger
+	}
+	return spanLogger(logger, span, enabledLevel...)
+}
+
+// spanLogger binds logger to the span, which is an opentracing.Span or an
+// OpenTelemetry trace.Span, unless it is already bound to it.
+func spanLogger(logger Logger, span interface{}, enabledLevel ...Level) Logger {
+	level := DefaultSpanLevel
 	if len(enabledLevel) > 0 {
-		return logger.WithTargets(OutputToTracer(enabledLevel[0], span))
+		level = enabledLevel[0]
+	}
+	if isBoundTo(logger, span) {
+		return logger
 	}
 
-	return logger.WithTargets(OutputToTracer(DefaultSpanLevel, span))
+	switch s := span.(type) {
+	case opentracing.Span:
+		return bindSpan(withTraceDebug(withSpanIDs(logger, s), s).WithTargets(OutputToTracer(level, s)), s)
+	case trace.Span:
+		return bindSpan(withTraceDebug(withSpanIDs(logger, s), s).WithTargets(OutputToOTel(level, s)), s)
+	}
+	return logger
+}
+
+// bindSpan marks the logger returned by Span as bound to the span.
+func bindSpan(logger Logger, span interface{}) Logger {
+	l, ok := asAppendLogger(logger)
+	if !ok {
+		return logger
+	}
+	l.span = span
+	return l
+}
+
+// asAppendLogger returns logger as an appendLogger which records what For
+// added to it, ok is false for the empty logger.
+func asAppendLogger(logger Logger) (appendLogger, bool) {
+	switch l := logger.(type) {
+	case appendLogger:
+		return l, true
+	case emptyLogger:
+		return appendLogger{}, false
+	}
+	return appendLogger{logger: logger.AddCallerSkip(1), target: Tee(nil)}, true
 }
 
+// isBoundTo reports whether the logger is already bound to the span by Span,
+// or to the TraceContext by For.
+func isBoundTo(logger Logger, span interface{}) bool {
+	l, ok := logger.(appendLogger)
+	if !ok || l.span == nil || span == nil {
+		return false
+	}
+	if tc, ok := span.(TraceContext); ok {
+		bound, ok := l.span.(TraceContext)
+		return ok && bound.TraceID == tc.TraceID && bound.SpanID == tc.SpanID
+	}
+	if traceID, spanID, ok := SpanIDs(span); ok {
+		boundTraceID, boundSpanID, ok := SpanIDs(l.span)
+		return ok && boundTraceID == traceID && boundSpanID == spanID
+	}
+	typ := reflect.TypeOf(span)
+	return typ == reflect.TypeOf(l.span) && typ.Comparable() && l.span == span
+}
+
+// withSpanIDs adds the trace id and span id fields and the allowed baggage
+// items of the span to logger.
+func withSpanIDs(logger Logger, span interface{}) Logger {
+	fields := spanIDFields(span)
+	if s, ok := span.(opentracing.Span); ok && len(BaggageFields) > 0 {
+		fields = append(fields, spanBaggageFields(s)...)
+	}
+	if len(fields) > 0 {
+		return logger.With(fields...)
+	}
+	return logger
+}
+
+// SpanFromContext returns a Logger which also echoes the entries into the
+// opentracing or OpenTelemetry span in the ctx.
 func SpanFromContext(ctx context.Context, logger Logger) Logger {
 	if span := opentracing.SpanFromContext(ctx); span != nil {
 		return Span(logger, span)
 	}
+	if span := OTelSpanFromContext(ctx); span != nil {
+		return OTelSpan(withOTelBaggage(logger, ctx), span)
+	}
 	return logger
 }
 
+// SpanContext starts a child span of the spanContext by the global
+// opentracing tracer, and returns a Logger which also echoes the entries into
+// the child span.
 func SpanContext(logger Logger, spanContext opentracing.SpanContext, method string, enabledLevel ...Level) (Logger, func()) {
 	if spanContext == nil {
-		return logger, func() {}
+		return logger, noop
 	}
-
 	span := opentracing.StartSpan(method, opentracing.ChildOf(spanContext))
-	finish := func() {
+	return Span(logger, span, enabledLevel...), func() {
 		span.Finish()
 	}
+}
 
-	if len(enabledLevel) > 0 {
-		return logger.WithTargets(OutputToTracer(enabledLevel[0], span)), finish
+// OTelSpanContext is SpanContext for an OpenTelemetry span context, the child
+// span is started by the tracer of OTelTracerName.
+func OTelSpanContext(logger Logger, spanContext trace.SpanContext, method string, enabledLevel ...Level) (Logger, func()) {
+	if !spanContext.IsValid() {
+		return logger, noop
+	}
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), spanContext)
+	_, span := otel.Tracer(OTelTracerName).Start(ctx, method)
+	return OTelSpan(logger, span, enabledLevel...), func() {
+		span.End()
 	}
-	return logger.WithTargets(OutputToTracer(DefaultSpanLevel, span)), finish
 }
 
 // For returns a context-aware Logger. If the context
-// contains an OpenTracing span, all logging calls are also
-// echo-ed into the span.
+// contains an OpenTracing or OpenTelemetry span, all logging
+// calls are also echo-ed into the span. Otherwise, if the
+// context contains a W3C TraceContext, its trace id and the
+// allowed baggage members are added as fields. The fields of
+// ContextWithFields are also added.
+//
+// The returned Logger records the span and the fields which
+// are added, so that For(ctx, For(ctx)) doesn't add them
+// twice, and only the fields which are added to ctx later
+// are added to the one of For(ctx).
 func For(ctx context.Context, args ...interface{}) Logger {
 	var logger Logger
-	var span opentracing.Span
-	var level = DefaultSpanLevel
+	var span interface{}
 	var fields []Field
 
+	level, ok := ctx.Value(activeSpanLevelKey).(Level)
+	if !ok {
+		level = DefaultSpanLevel
+	}
+
 	for _, arg := range args {
 		switch value := arg.(type) {
 		case Logger:
 			logger = value
 		case opentracing.Span:
 			span = value
+		case trace.Span:
+			span = value
 		case Level:
 			level = value
 		case Field:
@@ -77,8 +193,16 @@ func For(ctx context.Context, args ...interface{}) Logger {
 		}
 	}
 
+	otelBaggage := false
+	if span == nil {
+		span = contextSpan(ctx)
+		_, otelBaggage = span.(trace.Span)
+	}
+
 	if logger == nil {
 		logger = LoggerOrEmptyFromContext(ctx)
+	} else {
+		logger = withContextFields(ctx, logger)
 	}
 
 	if len(fields) > 0 {
@@ -86,34 +210,108 @@ func For(ctx context.Context, args ...interface{}) Logger {
 	}
 
 	if span != nil {
-		return Span(logger, span, level)
+		if otelBaggage && !isBoundTo(logger, span) {
+			logger = withOTelBaggage(logger, ctx)
+		}
+		return spanLogger(logger, span, level)
 	}
 
-	if span := opentracing.SpanFromContext(ctx); span != nil {
-		return Span(logger, span, level)
+	if tc, ok := TraceContextFromContext(ctx); ok && !isBoundTo(logger, tc) {
+		if fields := tc.Fields(); len(fields) > 0 {
+			logger = logger.With(fields...)
+		}
+		return bindSpan(withTraceDebug(logger, tc), tc)
 	}
 	return logger
 }
 
+// contextSpan returns the opentracing or OpenTelemetry span in the ctx, or
+// nil if there isn't one.
+func contextSpan(ctx context.Context) interface{} {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return span
+	}
+	if span := OTelSpanFromContext(ctx); span != nil {
+		return span
+	}
+	return nil
+}
+
+type spanLevelKey struct{}
+
+var activeSpanLevelKey = spanLevelKey{}
+
+// ContextWithSpanLevel returns a new `context.Context` that holds the minimum
+// level of the entries which For echoes into the span of the ctx, default is
+// DefaultSpanLevel.
+func ContextWithSpanLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, activeSpanLevelKey, level)
+}
+
+// withContext returns the Logger of For(ctx, logger) with the request id of
+// ctx for the XxxContext methods of the loggers, which skips their frames.
+// The request id is added unless the fields of the ctx have it.
+func withContext(ctx context.Context, logger Logger) Logger {
+	if ctx == nil {
+		return logger.AddCallerSkip(1)
+	}
+	logger = For(ctx, logger)
+	if id := RequestIDFromContext(ctx); id != "" &&
+		!hasField(FieldsFromContext(ctx), "request_id") {
+		logger = logger.With(String("request_id", id))
+	}
+	return logger.AddCallerSkip(1)
+}
+
 var noop = func() {}
 
 func IsEmpty(logger Logger) bool {
 	return logger == empty
 }
 
+// CloneContext returns a context which is never canceled and keeps the
+// logging values of src, see Detach. If to is given, the logger, fields,
+// spans, trace context, baggage, request id and the values of the keys of
+// RegisterContextKey are copied from src into it instead.
 func CloneContext(src context.Context, to ...context.Context) context.Context {
-	var dst = context.Background()
-	if len(to) > 0 {
-		dst = to[0]
+	if len(to) == 0 {
+		return Detach(src)
 	}
+	dst := to[0]
 	logger := LoggerFromContext(src)
 	if logger != nil {
 		dst = ContextWithLogger(dst, logger)
 	}
+	if fields := FieldsFromContext(src); len(fields) > 0 {
+		if len(FieldsFromContext(dst)) == 0 {
+			// keeps the fields node, so that the loggers derived from src
+			// don't add them twice
+			dst = context.WithValue(dst, activeFieldsKey, fieldsNodeFromContext(src))
+		} else {
+			dst = ContextWithFields(dst, fields...)
+		}
+	}
 
 	span := opentracing.SpanFromContext(src)
 	if span != nil {
 		dst = opentracing.ContextWithSpan(dst, span)
 	}
-	return dst
+
+	if otelSpan := OTelSpanFromContext(src); otelSpan != nil {
+		dst = trace.ContextWithSpan(dst, otelSpan)
+	}
+
+	if tc, ok := TraceContextFromContext(src); ok {
+		dst = ContextWithTraceContext(dst, tc)
+	}
+	if bag := baggage.FromContext(src); bag.Len() > 0 {
+		dst = baggage.ContextWithBaggage(dst, bag)
+	}
+	if id := RequestIDFromContext(src); id != "" {
+		dst = ContextWithRequestID(dst, id)
+	}
+	if level, ok := src.Value(activeSpanLevelKey).(Level); ok {
+		dst = ContextWithSpanLevel(dst, level)
+	}
+	return copyContextKeys(src, dst)
 }
@@ -0,0 +1,530 @@
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultSpoolMaxSize is the default cap on the total size of the spool.
+	DefaultSpoolMaxSize = 100 * 1024 * 1024
+	// DefaultSpoolSegmentSize is the default size of a spool segment file.
+	DefaultSpoolSegmentSize = 4 * 1024 * 1024
+	// DefaultSpoolRetryInterval is the default interval of retrying the primary.
+	DefaultSpoolRetryInterval = 5 * time.Second
+
+	spoolExt = ".spool"
+
+	// a record is the length and the crc32 of the payload, the unix nano
+	// time at which it is spooled and the payload.
+	spoolHeaderSize = 4 + 4 + 8
+)
+
+var errCorruptedRecord = errors.New("corrupted spool record")
+
+// FailoverConfig configures Failover.
+type FailoverConfig struct {
+	// Dir is the directory of the spool segment files.
+	Dir string
+
+	// MaxSize caps the total size of the spool, the oldest segments are
+	// dropped when it is exceeded, default is DefaultSpoolMaxSize.
+	MaxSize int64
+
+	// SegmentSize is the size at which a new segment file is started,
+	// default is DefaultSpoolSegmentSize.
+	SegmentSize int64
+
+	// RetryInterval is the interval of replaying the spool to the primary,
+	// default is DefaultSpoolRetryInterval.
+	RetryInterval time.Duration
+}
+
+// SpoolStats is a snapshot of the spool of a Failover.
+type SpoolStats struct {
+	Segments int
+	Bytes    int64
+	Records  int
+
+	// Oldest is the time at which the oldest record is spooled, zero if the
+	// spool is empty.
+	Oldest time.Time
+
+	// Dropped is the number of the records dropped because the spool is full.
+	Dropped uint64
+	// Corrupted is the number of the records skipped because of bad checksums.
+	Corrupted uint64
+}
+
+type segment struct {
+	seq     uint64
+	path    string
+	size    int64
+	records int
+	first   time.Time
+
+	// offset and replayed are the progress of replaying
+	offset   int64
+	replayed int
+}
+
+// Failover is a zapcore.WriteSyncer which writes to a primary destination.
+// When a write fails, the entries are spooled to segment files in a local
+// directory and replayed in order once the primary comes back, the entries
+// are written at least once.
+type Failover struct {
+	primary       zapcore.WriteSyncer
+	dir           string
+	maxSize       int64
+	segmentSize   int64
+	retryInterval time.Duration
+
+	mu        sync.Mutex
+	healthy   bool
+	replaying bool
+	segments  []*segment
+	active    *os.File
+	nextSeq   uint64
+	size      int64
+	dropped   uint64
+	corrupted uint64
+
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewFailover creates a Failover which writes to primary. The segments left
+// in the directory by a previous run are replayed first.
+func NewFailover(primary zapcore.WriteSyncer, cfg FailoverConfig) (*Failover, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("spool directory is missing")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultSpoolMaxSize
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = DefaultSpoolSegmentSize
+	}
+	if cfg.SegmentSize > cfg.MaxSize {
+		cfg.SegmentSize = cfg.MaxSize
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = DefaultSpoolRetryInterval
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	f := &Failover{
+		primary:       primary,
+		dir:           cfg.Dir,
+		maxSize:       cfg.MaxSize,
+		segmentSize:   cfg.SegmentSize,
+		retryInterval: cfg.RetryInterval,
+		healthy:       true,
+		closed:        make(chan struct{}),
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	if len(f.segments) > 0 {
+		f.healthy = false
+		f.startReplay()
+	}
+	return f, nil
+}
+
+// load reads the segments left in the directory.
+func (f *Failover) load() error {
+	files, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), spoolExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(fi.Name(), spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seg := &segment{seq: seq, path: filepath.Join(f.dir, fi.Name()), size: fi.Size()}
+		if err := scanSegment(seg); err != nil {
+			return err
+		}
+		f.segments = append(f.segments, seg)
+		f.size += seg.size
+		if seq >= f.nextSeq {
+			f.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(f.segments, func(i, j int) bool {
+		return f.segments[i].seq < f.segments[j].seq
+	})
+	return nil
+}
+
+// scanSegment counts the records of the segment and reads its first time.
+func scanSegment(seg *segment) error {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	remain := seg.size
+	for {
+		ts, payload, err := readRecord(r, remain)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF || err == errCorruptedRecord {
+				return nil
+			}
+			return err
+		}
+		remain -= int64(spoolHeaderSize + len(payload))
+		if seg.records == 0 {
+			seg.first = ts
+		}
+		seg.records++
+	}
+}
+
+// readRecord reads a record of the remain bytes of a segment, the length
+// isn't protected by the checksum, so a length which exceeds them is
+// corrupted.
+func readRecord(r io.Reader, remain int64) (time.Time, []byte, error) {
+	var header [spoolHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return time.Time{}, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	sum := binary.BigEndian.Uint32(header[4:8])
+	if int64(length) > remain-spoolHeaderSize {
+		return time.Time{}, nil, errCorruptedRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return time.Time{}, nil, err
+	}
+	if crc32.Update(crc32.ChecksumIEEE(header[8:]), crc32.IEEETable, payload) != sum {
+		return time.Time{}, nil, errCorruptedRecord
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(header[8:]))), payload, nil
+}
+
+func encodeRecord(ts time.Time, payload []byte) []byte {
+	record := make([]byte, spoolHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint64(record[8:16], uint64(ts.UnixNano()))
+	copy(record[spoolHeaderSize:], payload)
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(record[8:]))
+	return record
+}
+
+// Write writes p to the primary, or to the spool if the primary is down or
+// the spool isn't replayed yet.
+func (f *Failover) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.healthy {
+		n, err := f.primary.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		f.healthy = false
+	}
+
+	if err := f.spool(time.Now(), p); err != nil {
+		return 0, err
+	}
+	f.startReplay()
+	return len(p), nil
+}
+
+// spool appends a record to the active segment, it must be called with f.mu held.
+func (f *Failover) spool(ts time.Time, p []byte) error {
+	record := encodeRecord(ts, p)
+
+	var seg *segment
+	if f.active != nil {
+		seg = f.segments[len(f.segments)-1]
+		if seg.size+int64(len(record)) > f.segmentSize && seg.size > 0 {
+			f.closeActive()
+			seg = nil
+		}
+	}
+
+	// drop the oldest segments to make room
+	for len(f.segments) > 0 && f.size+int64(len(record)) > f.maxSize {
+		oldest := f.segments[0]
+		if oldest == seg {
+			break
+		}
+		f.dropped += uint64(oldest.records - oldest.replayed)
+		f.removeSegment(oldest)
+	}
+
+	if seg == nil {
+		seg = &segment{seq: f.nextSeq, path: filepath.Join(f.dir, fmt.Sprintf("%020d%s", f.nextSeq, spoolExt))}
+		file, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		f.nextSeq++
+		f.active = file
+		f.segments = append(f.segments, seg)
+	}
+
+	n, err := f.active.Write(record)
+	seg.size += int64(n)
+	f.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if seg.records == 0 {
+		seg.first = ts
+	}
+	seg.records++
+	return nil
+}
+
+// closeActive closes the active segment, it must be called with f.mu held.
+func (f *Failover) closeActive() {
+	if f.active != nil {
+		f.active.Close()
+		f.active = nil
+	}
+}
+
+// removeSegment deletes the segment, it must be called with f.mu held.
+func (f *Failover) removeSegment(seg *segment) {
+	for idx := range f.segments {
+		if f.segments[idx] != seg {
+			continue
+		}
+		if idx == len(f.segments)-1 {
+			f.closeActive()
+		}
+		f.segments = append(f.segments[:idx], f.segments[idx+1:]...)
+		f.size -= seg.size
+		os.Remove(seg.path)
+		return
+	}
+}
+
+// startReplay starts the replaying goroutine, it must be called with f.mu held.
+func (f *Failover) startReplay() {
+	if f.replaying {
+		return
+	}
+	select {
+	case <-f.closed:
+		return
+	default:
+	}
+	f.replaying = true
+	f.wg.Add(1)
+	go f.replayLoop()
+}
+
+func (f *Failover) replayLoop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.closed:
+			return
+		case <-ticker.C:
+		}
+		if f.replay() {
+			return
+		}
+	}
+}
+
+// replay writes the spooled records to the primary in order, it returns
+// true once the spool is empty and the primary is healthy again.
+func (f *Failover) replay() bool {
+	for {
+		f.mu.Lock()
+		if len(f.segments) == 0 {
+			f.healthy = true
+			f.replaying = false
+			f.mu.Unlock()
+			return true
+		}
+		seg := f.segments[0]
+		if len(f.segments) == 1 {
+			// new records go to a new segment from now on
+			f.closeActive()
+		}
+		f.mu.Unlock()
+
+		if err := f.replaySegment(seg); err != nil {
+			return false
+		}
+
+		f.mu.Lock()
+		f.removeSegment(seg)
+		f.mu.Unlock()
+	}
+}
+
+func (f *Failover) replaySegment(seg *segment) error {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// dropped because the spool is full
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	offset := seg.offset
+	f.mu.Unlock()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(file)
+	remain := fi.Size() - offset
+	for {
+		ts, payload, err := readRecord(r, remain)
+		if err != nil {
+			if err == io.EOF {
+				// the health is decided by the writes only, a primary such
+				// as stdout may always fail to sync
+				f.primary.Sync()
+				return nil
+			}
+			// skip the rest of the segment
+			f.mu.Lock()
+			if n := seg.records - seg.replayed; n > 1 {
+				f.corrupted += uint64(n)
+			} else {
+				f.corrupted++
+			}
+			f.mu.Unlock()
+			return nil
+		}
+
+		f.mu.Lock()
+		seg.first = ts
+		f.mu.Unlock()
+
+		if _, err := f.primary.Write(payload); err != nil {
+			return err
+		}
+
+		remain -= int64(spoolHeaderSize + len(payload))
+		f.mu.Lock()
+		seg.offset += int64(spoolHeaderSize + len(payload))
+		seg.replayed++
+		f.mu.Unlock()
+	}
+}
+
+// Sync flushes the primary, or the active segment if the primary is down.
+func (f *Failover) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.healthy {
+		return f.primary.Sync()
+	}
+	if f.active != nil {
+		return f.active.Sync()
+	}
+	return nil
+}
+
+// Close stops replaying and closes the active segment, the primary isn't
+// closed and the spooled records are replayed by the next Failover on the
+// same directory.
+func (f *Failover) Close() error {
+	f.mu.Lock()
+	select {
+	case <-f.closed:
+		f.mu.Unlock()
+		return nil
+	default:
+	}
+	close(f.closed)
+	f.mu.Unlock()
+
+	f.wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replaying = false
+	if f.active == nil {
+		return nil
+	}
+	err := f.active.Close()
+	f.active = nil
+	return err
+}
+
+// Stats returns a snapshot of the spool.
+func (f *Failover) Stats() SpoolStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := SpoolStats{
+		Segments:  len(f.segments),
+		Bytes:     f.size,
+		Dropped:   f.dropped,
+		Corrupted: f.corrupted,
+	}
+	for _, seg := range f.segments {
+		stats.Records += seg.records - seg.replayed
+	}
+	if len(f.segments) > 0 {
+		stats.Oldest = f.segments[0].first
+	}
+	return stats
+}
+
+// RegisterMetrics adds the spool size and age gauges to m.
+func (f *Failover) RegisterMetrics(m *Metrics) {
+	m.Gauge("spool_bytes", "Size of the log spool in bytes.", func() float64 {
+		return float64(f.Stats().Bytes)
+	})
+	m.Gauge("spool_records", "Number of the records in the log spool.", func() float64 {
+		return float64(f.Stats().Records)
+	})
+	m.Gauge("spool_oldest_age_seconds", "Age of the oldest record in the log spool.", func() float64 {
+		oldest := f.Stats().Oldest
+		if oldest.IsZero() {
+			return 0
+		}
+		return time.Since(oldest).Seconds()
+	})
+	m.Gauge("spool_dropped_records", "Number of the records dropped because the log spool is full.", func() float64 {
+		return float64(f.Stats().Dropped)
+	})
+}
@@ -0,0 +1,197 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type flakyWriter struct {
+	mu      sync.Mutex
+	down    bool
+	buf     bytes.Buffer
+	syncErr error
+}
+
+func (w *flakyWriter) setDown(down bool) {
+	w.mu.Lock()
+	w.down = down
+	w.mu.Unlock()
+}
+
+func (w *flakyWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.down {
+		return 0, errors.New("primary is down")
+	}
+	return w.buf.Write(p)
+}
+
+func (w *flakyWriter) Sync() error {
+	return w.syncErr
+}
+
+func TestFailoverReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	primary := &flakyWriter{}
+	failover, err := NewFailover(primary, FailoverConfig{
+		Dir:           dir,
+		SegmentSize:   40,
+		RetryInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failover.Close()
+
+	failover.Write([]byte("a\n"))
+	primary.setDown(true)
+	for _, s := range []string{"b\n", "c\n", "d\n"} {
+		if _, err := failover.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := failover.Stats()
+	if stats.Records != 3 || stats.Segments != 2 || stats.Oldest.IsZero() {
+		t.Errorf("%#v", stats)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.spool")); len(files) != 2 {
+		t.Error(files)
+	}
+
+	primary.setDown(false)
+	failover.Write([]byte("e\n"))
+
+	for i := 0; i < 100 && failover.Stats().Records > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	failover.Write([]byte("f\n"))
+
+	if s := primary.String(); s != "a\nb\nc\nd\ne\nf\n" {
+		t.Error(strings.Split(s, "\n"))
+	}
+	if stats := failover.Stats(); stats.Bytes != 0 || stats.Segments != 0 {
+		t.Errorf("%#v", stats)
+	}
+}
+
+func TestFailoverReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	primary := &flakyWriter{down: true}
+	failover, err := NewFailover(primary, FailoverConfig{Dir: dir, RetryInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failover.Write([]byte("a\n"))
+	failover.Write([]byte("b\n"))
+	failover.Close()
+
+	// corrupt the second record
+	files, _ := filepath.Glob(filepath.Join(dir, "*.spool"))
+	data, _ := ioutil.ReadFile(files[0])
+	data[len(data)-1] = 'x'
+	ioutil.WriteFile(files[0], data, 0644)
+
+	primary.setDown(false)
+	failover, err = NewFailover(primary, FailoverConfig{Dir: dir, RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failover.Close()
+
+	for i := 0; i < 100 && failover.Stats().Segments > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := primary.String(); s != "a\n" {
+		t.Error(s)
+	}
+	if stats := failover.Stats(); stats.Corrupted != 1 {
+		t.Errorf("%#v", stats)
+	}
+}
+
+func TestFailoverCorruptedLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the checksum doesn't protect the length
+	record := encodeRecord(time.Now(), []byte("a\n"))
+	binary.BigEndian.PutUint32(record[0:4], math.MaxUint32)
+	ioutil.WriteFile(filepath.Join(dir, "00000000000000000000.spool"), record, 0644)
+
+	primary := &flakyWriter{}
+	failover, err := NewFailover(primary, FailoverConfig{Dir: dir, RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failover.Close()
+
+	for i := 0; i < 100 && failover.Stats().Segments > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := primary.String(); s != "" {
+		t.Error(s)
+	}
+	if stats := failover.Stats(); stats.Corrupted != 1 || stats.Segments != 0 {
+		t.Errorf("%#v", stats)
+	}
+}
+
+func TestFailoverSyncError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// such as the EINVAL of syncing stdout
+	primary := &flakyWriter{down: true, syncErr: errors.New("invalid argument")}
+	failover, err := NewFailover(primary, FailoverConfig{Dir: dir, RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failover.Close()
+
+	failover.Write([]byte("a\n"))
+	primary.setDown(false)
+	for i := 0; i < 100 && failover.Stats().Segments > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	failover.Write([]byte("b\n"))
+
+	if s := primary.String(); s != "a\nb\n" {
+		t.Error(strings.Split(s, "\n"))
+	}
+	if stats := failover.Stats(); stats.Segments != 0 || stats.Records != 0 {
+		t.Errorf("%#v", stats)
+	}
+}
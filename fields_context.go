@@ -0,0 +1,110 @@
+package log
+
+import "context"
+
+type fieldsKey struct{}
+
+var activeFieldsKey = fieldsKey{}
+
+// fieldsNode holds the fields of a ctx, parent is the fieldsNode of the
+// ctx which it is derived from, so that a Logger which already has the
+// fields of the parent adds only the new ones.
+type fieldsNode struct {
+	parent *fieldsNode
+	fields []Field
+}
+
+// since returns the fields which are added after merged, or all the fields
+// if merged isn't one of the ancestors.
+func (cf *fieldsNode) since(merged *fieldsNode) []Field {
+	if merged == nil {
+		return cf.fields
+	}
+	for node := cf; node != nil; node = node.parent {
+		if node == merged {
+			return cf.fields[len(merged.fields):]
+		}
+	}
+	return cf.fields
+}
+
+func fieldsNodeFromContext(ctx context.Context) *fieldsNode {
+	cf, _ := ctx.Value(activeFieldsKey).(*fieldsNode)
+	return cf
+}
+
+// ContextWithFields returns a new `context.Context` that holds the fields in
+// addition to the ones already associated with `ctx`. For,
+// LoggerOrEmptyFromContext and the slog Handler of ToSlogger add them to the
+// entries, so that a middleware can add them before a Logger is chosen.
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	parent := fieldsNodeFromContext(ctx)
+	old := FieldsFromContext(ctx)
+	merged := make([]Field, 0, len(old)+len(fields))
+	merged = append(merged, old...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, activeFieldsKey, &fieldsNode{parent: parent, fields: merged})
+}
+
+// FieldsFromContext returns the fields previously associated with `ctx` by
+// ContextWithFields.
+func FieldsFromContext(ctx context.Context) []Field {
+	if cf := fieldsNodeFromContext(ctx); cf != nil {
+		return cf.fields
+	}
+	return nil
+}
+
+// hasField reports whether one of the fields has the key.
+func hasField(fields []Field, key string) bool {
+	for _, field := range fields {
+		if field.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// withContextFields adds the fields of the ctx which logger doesn't have yet
+// to it, the returned Logger records them so that they aren't added twice.
+func withContextFields(ctx context.Context, logger Logger) Logger {
+	cf := fieldsNodeFromContext(ctx)
+	if cf == nil {
+		return logger
+	}
+	l, ok := asAppendLogger(logger)
+	if !ok {
+		return logger.With(cf.fields...)
+	}
+	fields := cf.since(l.fields)
+	if len(fields) == 0 {
+		return logger
+	}
+	l = l.With(fields...).(appendLogger)
+	l.fields = cf
+	return l
+}
+
+// contextWithMergedLogger returns a new `context.Context` that holds the
+// logger which already has the fields of `ctx`, and the fields are reset so
+// that they aren't added twice.
+func contextWithMergedLogger(ctx context.Context, logger Logger) context.Context {
+	ctx = ContextWithLogger(ctx, logger)
+	if len(FieldsFromContext(ctx)) > 0 {
+		ctx = context.WithValue(ctx, activeFieldsKey, &fieldsNode{})
+	}
+	return ctx
+}
+
+// contextFields returns the fields of the ctx and its allowed
+// baggage items, they are added to the records of the slog Handler.
+func contextFields(ctx context.Context) []Field {
+	fields := FieldsFromContext(ctx)
+	if baggage := BaggageFieldsFromContext(ctx); len(baggage) > 0 {
+		fields = append(fields[:len(fields):len(fields)], baggage...)
+	}
+	return fields
+}
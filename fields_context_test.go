@@ -0,0 +1,44 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContextWithFields(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	ctx := ContextWithFields(context.Background(), String("request_id", "1"))
+	ctx = ContextWithLogger(ctx, logger)
+	ctx = ContextWithFields(ctx, String("user_id", "2"))
+
+	For(ctx).Info("for")
+	For(ctx, logger).Info("for logger")
+	LoggerOrEmptyFromContext(ctx).Info("or empty")
+	logger.ToSlogger().InfoCtx(ctx, "slog")
+	For(CloneContext(ctx)).Info("clone")
+
+	spanCtx, _, finish := StartSpan(ctx, "op")
+	For(ContextWithFields(spanCtx, String("step", "3"))).Info("span")
+	finish(nil)
+
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "1" || fields["user_id"] != "2" {
+			t.Error(entry.Message, fields)
+		}
+		if entry.Message == "span" && fields["step"] != "3" {
+			t.Error(entry.Message, fields)
+		}
+		if len(entry.Context) != len(fields) {
+			t.Error("duplicated fields", entry.Message, entry.Context)
+		}
+	}
+	if n := logs.Len(); n != 7 {
+		t.Error(n)
+	}
+}
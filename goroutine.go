@@ -0,0 +1,85 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Launcher starts the goroutines which carry the logging context of their
+// parents and log their panics.
+type Launcher struct {
+	// Lifecycle logs the start and the finish with the duration of the
+	// goroutines.
+	Lifecycle bool
+	// Level is the level of the start and finish entries.
+	Level Level
+	// Recoverer logs the panics of the goroutines.
+	Recoverer Recoverer
+}
+
+// DefaultLauncher is the Launcher used by Go.
+var DefaultLauncher = Launcher{
+	Level:     DebugLevel,
+	Recoverer: Recoverer{Level: ErrorLevel, Message: "panic recovered"},
+}
+
+// Go starts a goroutine with DefaultLauncher, see Launcher.Go.
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	DefaultLauncher.Go(ctx, name, fn)
+}
+
+// Go calls fn in a new goroutine with a detached context of CloneContext,
+// which keeps the values of ctx but not its cancellation and deadline. The
+// logger is named by name and bound to the span of ctx by For, and the panics
+// of fn are recovered, mark the span as failed and are logged with the stack,
+// by NewStdDefaultLogger if ctx has no Logger.
+//
+//	log.Go(ctx, "refresh", func(ctx context.Context) {
+//		log.For(ctx).Info("refreshing")
+//	})
+func (l Launcher) Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	ctx = CloneContext(ctx)
+	if logger := LoggerFromContext(ctx); logger != nil {
+		ctx = ContextWithLogger(ctx, logger.Named(name))
+	}
+
+	go func() {
+		start := time.Now()
+		logger := For(ctx)
+		defer func() {
+			if value := recover(); value != nil {
+				failSpan(ctx, name+" panicked")
+				if LoggerFromContext(ctx) == nil {
+					// the panic isn't lost without a Logger in the ctx
+					logger = For(ctx, NewStdDefaultLogger())
+				}
+				l.Recoverer.LogPanic(logger, value, String("goroutine", name))
+				if l.Recoverer.RePanic {
+					panic(value)
+				}
+				return
+			}
+			if l.Lifecycle {
+				LogAt(logger, l.Level, name+" finished", Duration("duration", time.Since(start)))
+			}
+		}()
+
+		if l.Lifecycle {
+			LogAt(logger, l.Level, name+" started")
+		}
+		fn(ctx)
+	}()
+}
+
+// failSpan marks the opentracing or OpenTelemetry span of the ctx as failed.
+func failSpan(ctx context.Context, description string) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		ext.Error.Set(span, true)
+	} else if span := OTelSpanFromContext(ctx); span != nil {
+		span.SetStatus(codes.Error, description)
+	}
+}
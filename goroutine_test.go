@@ -0,0 +1,91 @@
+package log
+
+import (
+	"context"
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestGo(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	ctx := ContextWithLogger(context.Background(), NewLogger(zap.New(core)))
+	ctx = ContextWithFields(ctx, String("request_id", "1"))
+	span := mocktracer.New().StartSpan("request").(*mocktracer.MockSpan)
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	ctx, cancel := context.WithCancel(ctx)
+
+	launcher := DefaultLauncher
+	launcher.Lifecycle = true
+
+	done := make(chan struct{})
+	launcher.Go(ctx, "worker", func(ctx context.Context) {
+		defer close(done)
+		cancel()
+		if ctx.Err() != nil {
+			t.Error("context isn't detached")
+		}
+		For(ctx).Info("working")
+		panic("boom")
+	})
+	<-done
+
+	for i := 0; i < 100 && logs.Len() < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries := logs.All()
+	if len(entries) != 3 {
+		t.Fatal(entries)
+	}
+	for idx, message := range []string{"worker started", "working", "panic recovered"} {
+		fields := entries[idx].ContextMap()
+		if entries[idx].Message != message || entries[idx].LoggerName != "worker" ||
+			fields["request_id"] != "1" || fields["span_id"] == nil || len(fields) != len(entries[idx].Context) {
+			t.Error(entries[idx].LoggerName, entries[idx].Message, entries[idx].Context)
+		}
+	}
+	if fields := entries[2].ContextMap(); fields["panic"] != "boom" || fields["goroutine"] != "worker" {
+		t.Error(fields)
+	}
+	if records := span.Logs(); len(records) != 3 {
+		t.Error(records)
+	}
+	if span.Tag("error") != true {
+		t.Error(span.Tags())
+	}
+}
+
+func TestGoWithoutLogger(t *testing.T) {
+	out := make(chan string, 1)
+	stdlog.SetOutput(writerFunc(func(p []byte) (int, error) {
+		out <- string(p)
+		return len(p), nil
+	}))
+	defer stdlog.SetOutput(os.Stderr)
+
+	Go(context.Background(), "worker", func(ctx context.Context) {
+		panic("boom")
+	})
+
+	select {
+	case entry := <-out:
+		if !strings.Contains(entry, "panic recovered") || !strings.Contains(entry, "boom") {
+			t.Error(entry)
+		}
+	case <-time.After(time.Second):
+		t.Error("panic isn't logged")
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (fn writerFunc) Write(p []byte) (int, error) {
+	return fn(p)
+}
@@ -0,0 +1,313 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// RequestIDHeader is the default header of the request id.
+const RequestIDHeader = "X-Request-Id"
+
+// AccessLogFields selects the fields of the access log entries.
+type AccessLogFields uint
+
+// The fields of the access log entries.
+const (
+	AccessLogMethod AccessLogFields = 1 << iota
+	AccessLogPath
+	AccessLogQuery
+	AccessLogProto
+	AccessLogStatus
+	AccessLogBytes
+	AccessLogLatency
+	AccessLogRemoteAddr
+	AccessLogUserAgent
+	AccessLogReferer
+
+	// DefaultAccessLogFields are the fields logged by default.
+	DefaultAccessLogFields = AccessLogMethod | AccessLogPath | AccessLogStatus |
+		AccessLogBytes | AccessLogLatency | AccessLogRemoteAddr | AccessLogUserAgent
+)
+
+// DefaultStatusLevels are the levels of the access log entries by the status
+// class, such as 4 for 4xx.
+var DefaultStatusLevels = map[int]Level{
+	1: InfoLevel,
+	2: InfoLevel,
+	3: InfoLevel,
+	4: WarnLevel,
+	5: ErrorLevel,
+}
+
+// HTTPConfig configures the middleware of NewHTTPMiddleware.
+type HTTPConfig struct {
+	// RequestIDHeader is the header which propagates the request id, default
+	// is RequestIDHeader.
+	RequestIDHeader string
+
+	// NewRequestID generates the id of the requests without one, default is
+	// NewRequestID.
+	NewRequestID func() string
+
+	// Fields are the fields of the access log entries, default is
+	// DefaultAccessLogFields.
+	Fields AccessLogFields
+
+	// StatusLevels are the levels of the access log entries by the status
+	// class, default is DefaultStatusLevels. The entries of the classes
+	// which aren't in it are logged at InfoLevel.
+	StatusLevels map[int]Level
+
+	// SkipPaths are the paths whose access log entries are skipped, such as
+	// "/healthz".
+	SkipPaths []string
+
+	// Skip reports whether the access log entry of the request is skipped.
+	Skip func(r *http.Request) bool
+
+	// SpanLevel is the minimum level of the entries echoed into the span of
+	// the request, default is DefaultSpanLevel.
+	SpanLevel *Level
+
+	// Message is the message of the access log entries, default is
+	// "http request".
+	Message string
+}
+
+// NewRequestID returns a random 16 bytes hex string, it is the default
+// generator of the request ids.
+func NewRequestID() string {
+	return randomHex(16)
+}
+
+type requestIDKey struct{}
+
+var activeRequestIDKey = requestIDKey{}
+
+// ContextWithRequestID returns a new `context.Context` that holds the id.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, activeRequestIDKey, id)
+}
+
+// RequestIDFromContext returns the request id previously associated with
+// `ctx`, or "" if there isn't one.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(activeRequestIDKey).(string)
+	return id
+}
+
+// NewHTTPMiddleware returns a net/http middleware which generates or
+// propagates the request id, adds the request_id field to the context of the
+// request by ContextWithFields, stores the Logger into it by
+// ContextWithLogger, and starts a span of the request if the global
+// opentracing tracer is registered. Otherwise the OpenTelemetry span in the
+// context of the request is used, or the W3C trace context of the request is
+// extracted. For(ctx) returns the Logger with all of them.
+//
+// When the request ends, even if the handler panics, the span is finished and
+// an access log entry is written with the status, bytes, latency, remote
+// address and user agent.
+//
+// The Logger is logger, or the one in the context of the request if logger is
+// nil.
+func NewHTTPMiddleware(logger Logger, cfg HTTPConfig) func(http.Handler) http.Handler {
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = RequestIDHeader
+	}
+	if cfg.NewRequestID == nil {
+		cfg.NewRequestID = NewRequestID
+	}
+	if cfg.Fields == 0 {
+		cfg.Fields = DefaultAccessLogFields
+	}
+	if cfg.StatusLevels == nil {
+		cfg.StatusLevels = DefaultStatusLevels
+	}
+	if cfg.SpanLevel == nil {
+		level := DefaultSpanLevel
+		cfg.SpanLevel = &level
+	}
+	if cfg.Message == "" {
+		cfg.Message = "http request"
+	}
+	skipPaths := make(map[string]struct{}, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skipPaths[path] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := r.Context()
+
+			id := r.Header.Get(cfg.RequestIDHeader)
+			if id == "" || len(id) > 128 {
+				id = cfg.NewRequestID()
+			}
+			w.Header().Set(cfg.RequestIDHeader, id)
+			ctx = ContextWithRequestID(ctx, id)
+			ctx = ContextWithFields(ctx, String("request_id", id))
+			if logger != nil {
+				ctx = ContextWithLogger(ctx, logger)
+			}
+
+			var span opentracing.Span
+			if isGlobalTracerRegistered() {
+				span = startHTTPSpan(r)
+				ctx = opentracing.ContextWithSpan(ctx, span)
+			} else if OTelSpanFromContext(ctx) == nil {
+				ctx = ExtractHTTP(ctx, r.Header)
+			}
+			ctx = ContextWithSpanLevel(ctx, *cfg.SpanLevel)
+			requestLogger := For(ctx)
+
+			sw := &statusWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				status := sw.status
+				if status == 0 {
+					status = http.StatusOK
+					if !completed {
+						// next panics before writing the response
+						status = http.StatusInternalServerError
+					}
+				}
+				if span != nil {
+					ext.HTTPStatusCode.Set(span, uint16(status))
+					if status >= 500 {
+						ext.Error.Set(span, true)
+					}
+					span.Finish()
+				}
+
+				if _, ok := skipPaths[r.URL.Path]; ok {
+					return
+				}
+				if cfg.Skip != nil && cfg.Skip(r) {
+					return
+				}
+
+				level, ok := cfg.StatusLevels[status/100]
+				if !ok {
+					level = InfoLevel
+				}
+				LogAt(requestLogger, level, cfg.Message,
+					accessLogFields(cfg.Fields, r, status, sw.bytes, time.Since(start))...)
+			}()
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			completed = true
+		})
+	}
+}
+
+// isGlobalTracerRegistered reports whether a global opentracing tracer is
+// registered, a NoopTracer, such as the one which resets it, isn't counted.
+func isGlobalTracerRegistered() bool {
+	if _, ok := opentracing.GlobalTracer().(opentracing.NoopTracer); ok {
+		return false
+	}
+	return opentracing.IsGlobalTracerRegistered()
+}
+
+func startHTTPSpan(r *http.Request) opentracing.Span {
+	tracer := opentracing.GlobalTracer()
+	var opts []opentracing.StartSpanOption
+	if sc, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header)); err == nil {
+		opts = append(opts, ext.RPCServerOption(sc))
+	} else {
+		opts = append(opts, ext.SpanKindRPCServer)
+	}
+	span := tracer.StartSpan("HTTP "+r.Method+" "+r.URL.Path, opts...)
+	ext.HTTPMethod.Set(span, r.Method)
+	ext.HTTPUrl.Set(span, r.URL.String())
+	return span
+}
+
+func accessLogFields(selected AccessLogFields, r *http.Request, status int, bytes int64, latency time.Duration) []Field {
+	fields := make([]Field, 0, 10)
+	if selected&AccessLogMethod != 0 {
+		fields = append(fields, String("method", r.Method))
+	}
+	if selected&AccessLogPath != 0 {
+		fields = append(fields, String("path", r.URL.Path))
+	}
+	if selected&AccessLogQuery != 0 && r.URL.RawQuery != "" {
+		fields = append(fields, String("query", r.URL.RawQuery))
+	}
+	if selected&AccessLogProto != 0 {
+		fields = append(fields, String("proto", r.Proto))
+	}
+	if selected&AccessLogStatus != 0 {
+		fields = append(fields, Int("status", status))
+	}
+	if selected&AccessLogBytes != 0 {
+		fields = append(fields, Int64("bytes", bytes))
+	}
+	if selected&AccessLogLatency != 0 {
+		fields = append(fields, Duration("latency", latency))
+	}
+	if selected&AccessLogRemoteAddr != 0 {
+		fields = append(fields, String("remote_addr", r.RemoteAddr))
+	}
+	if selected&AccessLogUserAgent != 0 {
+		fields = append(fields, String("user_agent", r.UserAgent()))
+	}
+	if selected&AccessLogReferer != 0 && r.Referer() != "" {
+		fields = append(fields, String("referer", r.Referer()))
+	}
+	return fields
+}
+
+// statusWriter records the status and the bytes of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(bs []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(bs)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		if w.status == 0 {
+			w.status = http.StatusSwitchingProtocols
+		}
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker isn't implemented by the http.ResponseWriter")
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,200 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	handler := NewHTTPMiddleware(logger, HTTPConfig{
+		SkipPaths: []string{"/healthz"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		For(r.Context()).Info("handle", String("id", RequestIDFromContext(r.Context())))
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set(RequestIDHeader, "abc")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get(RequestIDHeader) != "abc" {
+		t.Error(rec.Header())
+	}
+
+	entries := logs.TakeAll()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	if fields := entries[0].ContextMap(); fields["request_id"] != "abc" || fields["id"] != "abc" {
+		t.Error(fields)
+	}
+	access := entries[1]
+	fields := access.ContextMap()
+	if access.Level != InfoLevel || access.Message != "http request" ||
+		fields["request_id"] != "abc" || fields["method"] != "GET" || fields["path"] != "/users" ||
+		fields["status"] != int64(200) || fields["bytes"] != int64(5) || fields["user_agent"] != "test" {
+		t.Error(access.Level, fields)
+	}
+	if _, ok := fields["latency"]; !ok {
+		t.Error(fields)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	entries = logs.TakeAll()
+	if len(entries) != 2 || entries[1].Level != WarnLevel || entries[1].ContextMap()["request_id"] == "" {
+		t.Error(entries)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	if entries = logs.TakeAll(); len(entries) != 1 {
+		t.Error(entries)
+	}
+}
+
+func TestHTTPMiddlewareSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	handler := NewHTTPMiddleware(Empty(), HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opentracing.SpanFromContext(r.Context()) == nil {
+			t.Error("span not found")
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/jobs", nil))
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 || spans[0].OperationName != "HTTP POST /jobs" ||
+		spans[0].Tag("http.status_code") != uint16(500) || spans[0].Tag("error") != true {
+		t.Error(spans)
+	}
+}
+
+func TestHTTPMiddlewareSpanFields(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	core, logs := observer.New(DebugLevel)
+	handler := NewHTTPMiddleware(NewLogger(zap.New(core)), HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		For(r.Context()).Info("handle")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["trace_id"] == nil || fields["span_id"] == nil || fields["request_id"] == nil {
+			t.Error(entry.Message, fields)
+		}
+		if len(entry.Context) != len(fields) {
+			t.Error("duplicated fields", entry.Message, entry.Context)
+		}
+	}
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatal(spans)
+	}
+	if records := spans[0].Logs(); len(records) != 2 {
+		t.Error(records)
+	}
+}
+
+func TestHTTPMiddlewareDerivedLogger(t *testing.T) {
+	for _, traced := range []bool{false, true} {
+		tracer := mocktracer.New()
+		if traced {
+			opentracing.SetGlobalTracer(tracer)
+		}
+
+		core, logs := observer.New(DebugLevel)
+		handler := NewHTTPMiddleware(NewLogger(zap.New(core)), HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			derived := For(ctx)
+			For(ctx, derived).Info("derived")
+
+			ctx = ContextWithFields(ctx, String("user_id", "42"))
+			For(ctx, derived).Info("user")
+			For(ctx, For(ctx)).Info("user")
+		}))
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+		entries := logs.FilterMessageSnippet("user").AllUntimed()
+		entries = append(entries, logs.FilterMessage("derived").AllUntimed()...)
+		if len(entries) != 3 {
+			t.Fatal(traced, logs.AllUntimed())
+		}
+		for _, entry := range entries {
+			fields := entry.ContextMap()
+			if fields["trace_id"] == nil || fields["span_id"] == nil || fields["request_id"] == nil {
+				t.Error(traced, entry.Message, fields)
+			}
+			if entry.Message == "user" && fields["user_id"] != "42" {
+				t.Error(traced, entry.Message, fields)
+			}
+			if len(entry.Context) != len(fields) {
+				t.Error("duplicated fields", traced, entry.Message, entry.Context)
+			}
+		}
+		if traced {
+			if records := tracer.FinishedSpans()[0].Logs(); len(records) != 4 {
+				t.Error(records)
+			}
+		}
+	}
+}
+
+func TestHTTPMiddlewarePanic(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	core, logs := observer.New(DebugLevel)
+	handler := NewHTTPMiddleware(NewLogger(zap.New(core)), HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		For(r.Context()).Debug("debug")
+		panic("boom")
+	}))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic is recovered")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+
+	entries := logs.All()
+	if len(entries) != 2 || entries[1].Level != ErrorLevel || entries[1].ContextMap()["status"] != int64(500) {
+		t.Fatal(entries)
+	}
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 || spans[0].Tag("http.status_code") != uint16(500) {
+		t.Fatal(spans)
+	}
+	// the debug entry is echoed with the default span level
+	if records := spans[0].Logs(); len(records) != 2 {
+		t.Error(records)
+	}
+}
@@ -0,0 +1,412 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultJobLogMaxEntries is the default max count of the entries of a job.
+	DefaultJobLogMaxEntries = 1000
+
+	// DefaultJobLogMaxSize is the default max total size of the entries of a
+	// job.
+	DefaultJobLogMaxSize = 1 << 20
+)
+
+// JobLogConfig configures JobLogStore.
+type JobLogConfig struct {
+	// MaxEntries is the max count of the entries of a job, the oldest ones
+	// are dropped, default is DefaultJobLogMaxEntries.
+	MaxEntries int
+
+	// MaxSize is the max total size of the JSON encoded entries of a job, the
+	// oldest ones are dropped, default is DefaultJobLogMaxSize.
+	MaxSize int
+
+	// Dir is the directory where the entries of the jobs are persisted, one
+	// JSON lines file per job. The entries are kept in memory only if it is
+	// empty.
+	Dir string
+}
+
+// JobEntry is an entry of a job.
+type JobEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   Level                  `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// JobEntries is a page of the entries of a job.
+type JobEntries struct {
+	// Total is the count of the kept entries.
+	Total int `json:"total"`
+	// Dropped is the count of the entries dropped by the limits.
+	Dropped int `json:"dropped"`
+	// Entries are the entries of the page.
+	Entries []JobEntry `json:"entries"`
+}
+
+// JobLogStore keeps the structured entries of the background jobs, so that
+// they can be shown to the users, it is safe for concurrent use.
+type JobLogStore struct {
+	maxEntries int
+	maxSize    int
+	dir        string
+
+	mu   sync.Mutex
+	jobs map[string]*jobLog
+}
+
+type jobLog struct {
+	entries []JobEntry
+	sizes   []int
+	size    int
+	dropped int
+
+	// stale is the count of the dropped entries which are still in the file.
+	stale int
+}
+
+var (
+	errInvalidJobID     = errors.New("invalid job id")
+	errJobEntryTooLarge = errors.New("job log entry is too large")
+)
+
+// NewJobLogStore returns a JobLogStore, the entries persisted in cfg.Dir are
+// loaded when their job is accessed.
+func NewJobLogStore(cfg JobLogConfig) (*JobLogStore, error) {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultJobLogMaxEntries
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultJobLogMaxSize
+	}
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &JobLogStore{
+		maxEntries: cfg.MaxEntries,
+		maxSize:    cfg.MaxSize,
+		dir:        cfg.Dir,
+		jobs:       map[string]*jobLog{},
+	}, nil
+}
+
+// Target returns a Target which adds the entries to the job.
+func (s *JobLogStore) Target(jobID string) Target {
+	return Callback(func(level Level, msg string, fields ...Field) {
+		s.Append(jobID, level, msg, fields...)
+	})
+}
+
+// Append adds an entry to the job. The fields of an entry which is larger
+// than MaxSize are replaced by its truncated_size, and its message is cut to
+// fit.
+func (s *JobLogStore) Append(jobID string, level Level, msg string, fields ...Field) error {
+	entry := JobEntry{Time: time.Now(), Level: level, Message: msg}
+	if len(fields) > 0 {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, field := range fields {
+			field.AddTo(enc)
+		}
+		entry.Fields = enc.Fields
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		for key, value := range entry.Fields {
+			entry.Fields[key] = fmt.Sprint(value)
+		}
+		if line, err = json.Marshal(entry); err != nil {
+			return err
+		}
+	}
+	if len(line) >= s.maxSize {
+		if line, err = shortenEntry(&entry, len(line), s.maxSize); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.load(jobID)
+	if err != nil {
+		return err
+	}
+	job.entries = append(job.entries, entry)
+	job.sizes = append(job.sizes, len(line)+1)
+	job.size += len(line) + 1
+	s.truncate(job)
+
+	if s.dir == "" {
+		return nil
+	}
+	if job.stale > s.maxEntries {
+		return s.rewrite(jobID, job)
+	}
+	file, err := os.OpenFile(s.filename(jobID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Entries returns the entries of the job from the offset, all of them if
+// limit is not positive.
+func (s *JobLogStore) Entries(jobID string, offset, limit int) (JobEntries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.load(jobID)
+	if err != nil {
+		return JobEntries{}, err
+	}
+	if len(job.entries) == 0 && job.dropped == 0 {
+		// don't keep the unknown jobs
+		delete(s.jobs, jobID)
+	}
+
+	page := JobEntries{Total: len(job.entries), Dropped: job.dropped}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(job.entries) {
+		offset = len(job.entries)
+	}
+	end := len(job.entries)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	page.Entries = append([]JobEntry{}, job.entries[offset:end]...)
+	return page, nil
+}
+
+// Jobs returns the ids of the jobs, including the persisted ones.
+func (s *JobLogStore) Jobs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.jobs))
+	for id, job := range s.jobs {
+		if len(job.entries) > 0 || job.dropped > 0 {
+			ids = append(ids, id)
+		}
+	}
+	if s.dir != "" {
+		names, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			id, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(name), ".jsonl"))
+			if err != nil {
+				continue
+			}
+			if _, ok := s.jobs[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Remove removes the entries of the job.
+func (s *JobLogStore) Remove(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, jobID)
+	if s.dir == "" || jobID == "" {
+		return nil
+	}
+	if err := os.Remove(s.filename(jobID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP returns the ids of the jobs as JSON, or the page of the entries of
+// the job if the job parameter is given, such as
+// "?job=123&offset=0&limit=100".
+func (s *JobLogStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	jobID := query.Get("job")
+
+	var result interface{}
+	var err error
+	if jobID == "" {
+		result, err = s.Jobs()
+	} else {
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		result, err = s.Entries(jobID, offset, limit)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == errInvalidJobID {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(result)
+}
+
+// load returns the log of the job, which is read from its file at the first
+// access.
+func (s *JobLogStore) load(jobID string) (*jobLog, error) {
+	if jobID == "" {
+		return nil, errInvalidJobID
+	}
+	if job, ok := s.jobs[jobID]; ok {
+		return job, nil
+	}
+
+	job := &jobLog{}
+	if s.dir != "" {
+		file, err := os.Open(s.filename(jobID))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			err = s.read(file, job)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	s.jobs[jobID] = job
+	return job, nil
+}
+
+// read reads the entries of the job from its file, the invalid lines and the
+// ones which are larger than MaxSize, such as the ones written with a larger
+// MaxSize, are skipped.
+func (s *JobLogStore) read(r io.Reader, job *jobLog) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSuffix(line, []byte("\n")); len(line) > 0 && len(line) < s.maxSize {
+			var entry JobEntry
+			if json.Unmarshal(line, &entry) == nil {
+				job.entries = append(job.entries, entry)
+				job.sizes = append(job.sizes, len(line)+1)
+				job.size += len(line) + 1
+				s.truncate(job)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// shortenEntry replaces the fields of the entry whose JSON encoded size is
+// larger than maxSize with the size, and cuts its message to fit.
+func shortenEntry(entry *JobEntry, size, maxSize int) ([]byte, error) {
+	entry.Fields = map[string]interface{}{"truncated_size": size}
+	message := entry.Message
+	for {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < maxSize {
+			return line, nil
+		}
+		if entry.Message == "" {
+			return nil, errJobEntryTooLarge
+		}
+		cut := len(entry.Message) - (len(line) - maxSize + 1)
+		if cut < 0 {
+			cut = 0
+		}
+		for cut > 0 && !utf8.RuneStart(message[cut]) {
+			cut--
+		}
+		entry.Message = message[:cut]
+	}
+}
+
+// truncate drops the oldest entries over the limits.
+func (s *JobLogStore) truncate(job *jobLog) {
+	drop := 0
+	for drop < len(job.entries)-1 &&
+		(len(job.entries)-drop > s.maxEntries || job.size > s.maxSize) {
+		job.size -= job.sizes[drop]
+		drop++
+	}
+	if drop == 0 {
+		return
+	}
+	job.entries = append(job.entries[:0:0], job.entries[drop:]...)
+	job.sizes = append(job.sizes[:0:0], job.sizes[drop:]...)
+	job.dropped += drop
+	job.stale += drop
+}
+
+// rewrite writes the kept entries of the job into its file.
+func (s *JobLogStore) rewrite(jobID string, job *jobLog) error {
+	filename := s.filename(jobID)
+	file, err := os.Create(filename + ".tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for idx := range job.entries {
+		if err = enc.Encode(job.entries[idx]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(filename+".tmp", filename)
+	}
+	if err != nil {
+		os.Remove(filename + ".tmp")
+		return err
+	}
+	job.stale = 0
+	return nil
+}
+
+func (s *JobLogStore) filename(jobID string) string {
+	return filepath.Join(s.dir, url.PathEscape(jobID)+".jsonl")
+}
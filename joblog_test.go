@@ -0,0 +1,121 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJobLogStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "joblog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewJobLogStore(JobLogConfig{MaxEntries: 5, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := Empty().WithTargets(store.Target("job/1"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.Info("step", Int("i", i))
+		}(i)
+	}
+	wg.Wait()
+	for i := 4; i < 8; i++ {
+		logger.Error("step", Int("i", i), Error(errors.New("failed")))
+	}
+
+	page, err := store.Entries("job/1", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 5 || page.Dropped != 3 || len(page.Entries) != 2 ||
+		page.Entries[0].Fields["i"] != int64(4) || page.Entries[0].Fields["error"] != "failed" {
+		t.Errorf("%#v", page)
+	}
+
+	reloaded, err := NewJobLogStore(JobLogConfig{MaxEntries: 5, Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs, err := reloaded.Jobs(); err != nil || len(jobs) != 1 || jobs[0] != "job/1" {
+		t.Error(jobs, err)
+	}
+
+	rec := httptest.NewRecorder()
+	reloaded.ServeHTTP(rec, httptest.NewRequest("GET", "/?job=job%2F1&offset=4", nil))
+	var result JobEntries
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err, rec.Body.String())
+	}
+	if result.Total != 5 || len(result.Entries) != 1 || result.Entries[0].Level != ErrorLevel ||
+		result.Entries[0].Message != "step" || result.Entries[0].Fields["i"] != float64(7) {
+		t.Errorf("%#v", result)
+	}
+
+	if err := reloaded.Remove("job/1"); err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := reloaded.Jobs(); len(jobs) != 0 {
+		t.Error(jobs)
+	}
+}
+
+func TestJobLogStoreMaxSize(t *testing.T) {
+	store, _ := NewJobLogStore(JobLogConfig{MaxSize: 300})
+	for i := 0; i < 10; i++ {
+		store.Append("a", InfoLevel, "message "+strconv.Itoa(i))
+	}
+	page, _ := store.Entries("a", 0, 0)
+	if page.Total == 0 || page.Total >= 10 || page.Entries[len(page.Entries)-1].Message != "message 9" {
+		t.Errorf("%#v", page)
+	}
+}
+
+func TestJobLogStoreOversizedEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "joblog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, _ := NewJobLogStore(JobLogConfig{MaxSize: 300, Dir: dir})
+	if err := store.Append("a", InfoLevel, strings.Repeat("m", 1000), String("s", strings.Repeat("f", 1000))); err != nil {
+		t.Fatal(err)
+	}
+
+	// a line written with a larger MaxSize is skipped
+	file, _ := os.OpenFile(store.filename("a"), os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString(`{"message":"` + strings.Repeat("x", 1000) + `"}` + "\n")
+	file.Close()
+
+	reloaded, _ := NewJobLogStore(JobLogConfig{MaxSize: 300, Dir: dir})
+	page, err := reloaded.Entries("a", 0, 0)
+	if err != nil || len(page.Entries) != 1 {
+		t.Fatalf("%#v %v", page, err)
+	}
+	if entry := page.Entries[0]; len(entry.Message) == 0 || len(entry.Message) >= 300 ||
+		entry.Fields["truncated_size"] == nil {
+		t.Errorf("%#v", entry)
+	}
+
+	if err := reloaded.Append("a", InfoLevel, "next"); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ = reloaded.Entries("a", 0, 0); page.Entries[len(page.Entries)-1].Message != "next" {
+		t.Errorf("%#v", page)
+	}
+}
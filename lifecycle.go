@@ -0,0 +1,163 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// SpanStarter starts child spans whose finish logs the completion with the
+// duration and the error.
+type SpanStarter struct {
+	// SpanLevel is the minimum level of the entries echoed into the span.
+	SpanLevel Level
+	// FinishLevel is the level of the completion entry.
+	FinishLevel Level
+	// ErrorLevel is the level of the entry of the error passed to finish.
+	ErrorLevel Level
+}
+
+// DefaultSpanStarter is the SpanStarter used by StartSpan.
+var DefaultSpanStarter = SpanStarter{
+	SpanLevel:   DefaultSpanLevel,
+	FinishLevel: DebugLevel,
+	ErrorLevel:  ErrorLevel,
+}
+
+// StartSpan starts a child span with DefaultSpanStarter, see SpanStarter.Start.
+func StartSpan(ctx context.Context, operationName string, args ...interface{}) (context.Context, Logger, func(err *error)) {
+	return DefaultSpanStarter.Start(ctx, operationName, args...)
+}
+
+// Start starts a child span of the span in the ctx, and returns a new ctx
+// which carries both the child span and its logger.
+//
+// args may be a Logger (default is the logger in the ctx), the parent
+// opentracing.SpanContext or OpenTelemetry trace.SpanContext, and Fields
+// which are added to both the logger and the tags of the span.
+//
+// finish logs the completion with the duration, or the error at ErrorLevel
+// and sets the error tag if err is not nil, then it finishes the span.
+//
+//	ctx, logger, finish := log.StartSpan(ctx, "query", log.String("table", "users"))
+//	defer finish(&err)
+func (s SpanStarter) Start(ctx context.Context, operationName string, args ...interface{}) (context.Context, Logger, func(err *error)) {
+	var logger Logger
+	var parent interface{}
+	var fields []Field
+
+	for _, arg := range args {
+		switch value := arg.(type) {
+		case Logger:
+			logger = value
+		case opentracing.SpanContext:
+			parent = value
+		case trace.SpanContext:
+			parent = value
+		case Field:
+			fields = append(fields, value)
+		}
+	}
+
+	if logger == nil {
+		logger = LoggerOrEmptyFromContext(ctx)
+	} else {
+		logger = withContextFields(ctx, logger)
+	}
+	if len(fields) > 0 {
+		logger = logger.With(fields...)
+	}
+
+	if parent == nil {
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			parent = span.Context()
+		} else if span := OTelSpanFromContext(ctx); span != nil {
+			parent = span.SpanContext()
+		}
+	}
+
+	var span interface{}
+	var end func(failed bool)
+	switch sc := parent.(type) {
+	case trace.SpanContext:
+		var otelSpan trace.Span
+		ctx, otelSpan = otel.Tracer(OTelTracerName).Start(trace.ContextWithSpanContext(ctx, sc), operationName)
+		if len(fields) > 0 {
+			otelSpan.SetAttributes(otelAttributes(fields)...)
+		}
+		span = otelSpan
+		end = func(failed bool) {
+			if failed {
+				otelSpan.SetStatus(codes.Error, operationName+" failed")
+			}
+			otelSpan.End()
+		}
+	default:
+		var opts []opentracing.StartSpanOption
+		if sc != nil {
+			opts = append(opts, opentracing.ChildOf(sc.(opentracing.SpanContext)))
+		}
+		if len(fields) > 0 {
+			enc := zapcore.NewMapObjectEncoder()
+			for _, field := range fields {
+				field.AddTo(enc)
+			}
+			opts = append(opts, opentracing.Tags(enc.Fields))
+		}
+		otSpan := opentracing.StartSpan(operationName, opts...)
+		ctx = opentracing.ContextWithSpan(ctx, otSpan)
+		span = otSpan
+		end = func(failed bool) {
+			if failed {
+				ext.Error.Set(otSpan, true)
+			}
+			otSpan.Finish()
+		}
+	}
+
+	// the ctx holds the logger without the span, so that For(ctx) and the
+	// children of the span bind their own spans only once
+	ctx = ContextWithSpanLevel(contextWithMergedLogger(ctx, logger), s.SpanLevel)
+	logger = spanLogger(logger, span, s.SpanLevel)
+
+	start := time.Now()
+	finishLogger := logger.AddCallerSkip(1)
+	finish := func(err *error) {
+		elapsed := time.Since(start)
+		if err != nil && *err != nil {
+			LogAt(finishLogger, s.ErrorLevel, operationName+" failed", Duration("duration", elapsed), Error(*err))
+			end(true)
+			return
+		}
+		LogAt(finishLogger, s.FinishLevel, operationName+" finished", Duration("duration", elapsed))
+		end(false)
+	}
+	return ctx, logger, finish
+}
+
+// LogAt logs the entry at the level, such as a configured level, the entries
+// at DPanicLevel are logged at ErrorLevel. The caller of LogAt is reported as
+// the caller of the entry.
+func LogAt(logger Logger, level Level, msg string, fields ...Field) {
+	logger = logger.AddCallerSkip(1)
+	switch level {
+	case DebugLevel:
+		logger.Debug(msg, fields...)
+	case InfoLevel:
+		logger.Info(msg, fields...)
+	case WarnLevel:
+		logger.Warn(msg, fields...)
+	case ErrorLevel, DPanicLevel:
+		logger.Error(msg, fields...)
+	case PanicLevel:
+		logger.Panic(msg, fields...)
+	case FatalLevel:
+		logger.Fatal(msg, fields...)
+	}
+}
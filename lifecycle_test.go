@@ -0,0 +1,101 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStartSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	core, logs := observer.New(DebugLevel)
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	ctx = ContextWithLogger(ctx, NewLogger(zap.New(core)))
+
+	run := func(ctx context.Context) (err error) {
+		ctx, logger, finish := StartSpan(ctx, "child", String("table", "users"))
+		defer finish(&err)
+
+		if LoggerFromContext(ctx) == nil || opentracing.SpanFromContext(ctx) == parent {
+			t.Error("ctx isn't updated")
+		}
+		logger.Info("running")
+		return errors.New("abc")
+	}
+	if err := run(ctx); err == nil {
+		t.Fatal("err is nil")
+	}
+
+	child := tracer.FinishedSpans()[0]
+	if child.OperationName != "child" ||
+		child.ParentID != parent.Context().(mocktracer.MockSpanContext).SpanID ||
+		child.Tag("table") != "users" ||
+		child.Tag("error") != true {
+		t.Error(child)
+	}
+	if len(child.Logs()) != 2 {
+		t.Error(child.Logs())
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 || entries[1].Message != "child failed" || entries[1].Level != ErrorLevel {
+		t.Fatal(entries)
+	}
+	fields := entries[1].ContextMap()
+	if fields["table"] != "users" || fields["error"] != "abc" || fields["duration"] == nil {
+		t.Error(fields)
+	}
+}
+
+func TestStartSpanNested(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	core, logs := observer.New(DebugLevel)
+	parent := tracer.StartSpan("parent")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	ctx = ContextWithLogger(ctx, NewLogger(zap.New(core)))
+
+	childCtx, _, finishChild := StartSpan(ctx, "child")
+	For(childCtx).Info("child")
+	grandCtx, _, finishGrand := StartSpan(childCtx, "grand")
+	For(grandCtx).Info("grand")
+	finishGrand(nil)
+	finishChild(nil)
+	parent.Finish()
+
+	spanIDs := map[string]string{}
+	for _, span := range tracer.FinishedSpans() {
+		spanIDs[span.OperationName] = strconv.Itoa(span.SpanContext.SpanID)
+		for _, record := range span.Logs() {
+			if event := record.Fields[0].ValueString; event != span.OperationName &&
+				event != span.OperationName+" finished" {
+				t.Error(span.OperationName, event)
+			}
+		}
+	}
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if len(entry.Context) != len(fields) {
+			t.Error("duplicated fields", entry.Message, entry.Context)
+		}
+		if name := strings.TrimSuffix(entry.Message, " finished"); fields["span_id"] != spanIDs[name] {
+			t.Error(entry.Message, fields, spanIDs)
+		}
+	}
+	if logs.Len() != 4 {
+		t.Error(logs.AllUntimed())
+	}
+}
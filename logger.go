@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"io"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -35,6 +36,25 @@ type Logger interface {
 	Warnf(msg string, values ...interface{})
 	Fatalf(msg string, values ...interface{})
 
+	// DebugContext, InfoContext, WarnContext and ErrorContext log with the
+	// span, the fields of ContextWithFields and the request id of ctx, which
+	// are pulled at call time like For(ctx, logger).
+	DebugContext(ctx context.Context, msg string, fields ...Field)
+	InfoContext(ctx context.Context, msg string, fields ...Field)
+	WarnContext(ctx context.Context, msg string, fields ...Field)
+	ErrorContext(ctx context.Context, msg string, fields ...Field)
+
+	// Every returns the Logger if its call site has not logged in the
+	// interval, or a Logger which discards everything otherwise, such as
+	// logger.Every(time.Minute).Warn(...).
+	Every(interval time.Duration) Logger
+	// EveryN returns the Logger for every n-th call of its call site, or a
+	// Logger which discards everything otherwise.
+	EveryN(n int) Logger
+	// Once returns the Logger for the first call of its call site, or a
+	// Logger which discards everything otherwise.
+	Once() Logger
+
 	AddCallerSkip(int) Logger
 	With(fields ...Field) Logger
 	WithTargets(targets ...Target) Logger
@@ -161,6 +181,34 @@ func (l zaplogger) Named(name string) Logger {
 	return zaplogger{logger: newL, sugared: newL.Sugar()}
 }
 
+func (l zaplogger) Every(interval time.Duration) Logger {
+	return every(l, interval)
+}
+
+func (l zaplogger) EveryN(n int) Logger {
+	return everyN(l, n)
+}
+
+func (l zaplogger) Once() Logger {
+	return once(l)
+}
+
+func (l zaplogger) DebugContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Debug(msg, fields...)
+}
+
+func (l zaplogger) InfoContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Info(msg, fields...)
+}
+
+func (l zaplogger) WarnContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Warn(msg, fields...)
+}
+
+func (l zaplogger) ErrorContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Error(msg, fields...)
+}
+
 func (l zaplogger) AddCallerSkip(level int) Logger {
 	logger := l.logger.WithOptions(zap.AddCallerSkip(level))
 	return zaplogger{logger: logger, sugared: logger.Sugar()}
@@ -171,25 +219,38 @@ func (l zaplogger) Unwrap() *zap.Logger {
 }
 
 func (l zaplogger) ToSlogger() *slog.Logger {
-	return slog.New(zapslog.NewHandler(l.logger.Core()))
+	return slog.New(zapslog.NewHandler(l.logger.Core(),
+		zapslog.WithContextFields(contextFields)))
 	// return slog.New(slogzap.Option{Level: slog.LevelInfo, Logger: env.Logger}.NewZapHandler())
 }
 
-func NewLogger(logger *zap.Logger) Logger {
+func NewLogger(logger *zap.Logger, opts ...Option) Logger {
 	logger = logger.WithOptions(zap.AddCallerSkip(1))
+	if len(opts) > 0 {
+		logger = logger.WithOptions(newOptions(opts).zapOptions()...)
+	}
 	return zaplogger{logger: logger, sugared: logger.Sugar()}
 }
 
-func NewZapLogger() Logger {
+func NewZapLogger(opts ...Option) Logger {
 	logConfig := zap.NewProductionConfig()
 	logger, err := logConfig.Build()
 	if err != nil {
 		panic(errors.New("init zap logger fail: " + err.Error()))
 	}
-	return NewLogger(logger)
+	return NewLogger(logger, opts...)
 }
 
 func NewFile(filename string, level ...Level) (Logger, io.WriteCloser) {
+	lvl := zapcore.DebugLevel
+	if len(level) > 0 {
+		lvl = level[0]
+	}
+	return NewFileWithOptions(filename, lvl)
+}
+
+// NewFileWithOptions is like NewFile, with the Options of the Logger.
+func NewFileWithOptions(filename string, level Level, opts ...Option) (Logger, io.WriteCloser) {
 	lumberJackLogger := &lumberjack.Logger{
 		Filename:   filename, // ⽇志⽂件路径
 		MaxSize:    5,        // 1M=1024KB=1024000byte
@@ -201,22 +262,18 @@ func NewFile(filename string, level ...Level) (Logger, io.WriteCloser) {
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 
-	lvl := zapcore.DebugLevel
-	if len(level) > 0 {
-		lvl = level[0]
-	}
 	core := zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(lumberJackLogger), lvl)
-	return NewLogger(zap.New(core, zap.AddCaller())), lumberJackLogger
+		zapcore.AddSync(lumberJackLogger), level)
+	return NewLogger(zap.New(core, zap.AddCaller()), opts...), lumberJackLogger
 }
 
-func NewDebugZapLogger() Logger {
+func NewDebugZapLogger(opts ...Option) Logger {
 	logConfig := zap.NewDevelopmentConfig()
 	logger, err := logConfig.Build()
 	if err != nil {
 		panic(errors.New("init zap logger fail: " + err.Error()))
 	}
-	return NewLogger(logger)
+	return NewLogger(logger, opts...)
 }
 
 // Logger is a simplified abstraction of the zap.Logger
@@ -250,6 +307,15 @@ func (empty emptyLogger) Errorf(msg string, values ...interface{}) {}
 func (empty emptyLogger) Warnf(msg string, values ...interface{})  {}
 func (empty emptyLogger) Fatalf(msg string, values ...interface{}) {}
 
+func (empty emptyLogger) Every(interval time.Duration) Logger { return empty }
+func (empty emptyLogger) EveryN(n int) Logger                 { return empty }
+func (empty emptyLogger) Once() Logger                        { return empty }
+
+func (empty emptyLogger) DebugContext(ctx context.Context, msg string, fields ...Field) {}
+func (empty emptyLogger) InfoContext(ctx context.Context, msg string, fields ...Field)  {}
+func (empty emptyLogger) WarnContext(ctx context.Context, msg string, fields ...Field)  {}
+func (empty emptyLogger) ErrorContext(ctx context.Context, msg string, fields ...Field) {}
+
 func (empty emptyLogger) AddCallerSkip(level int) Logger { return empty }
 func (empty emptyLogger) With(fields ...Field) Logger    { return empty }
 func (empty emptyLogger) Named(name string) Logger       { return empty }
@@ -279,7 +345,8 @@ func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
 }
 
 // LoggerFromContext returns the `logger` previously associated with `ctx`, or
-// `nil` if no such `logger` could be found.
+// `nil` if no such `logger` could be found. Unlike LoggerOrEmptyFromContext,
+// it doesn't add the fields of ContextWithFields.
 func LoggerFromContext(ctx context.Context, defaultLogger ...Logger) Logger {
 	val := ctx.Value(activeLoggerKey)
 	if sp, ok := val.(Logger); ok {
@@ -291,12 +358,13 @@ func LoggerFromContext(ctx context.Context, defaultLogger ...Logger) Logger {
 	return nil
 }
 
-// LoggerOrEmptyFromContext returns the `logger` previously associated with `ctx`, or
-// `Empty` if no such `logger` could be found.
+// LoggerOrEmptyFromContext returns the `logger` previously associated with `ctx`
+// with the fields of ContextWithFields, or `Empty` if no such `logger` could be
+// found.
 func LoggerOrEmptyFromContext(ctx context.Context) Logger {
 	val := ctx.Value(activeLoggerKey)
 	if sp, ok := val.(Logger); ok {
-		return sp
+		return withContextFields(ctx, sp)
 	}
 	return empty
 }
@@ -0,0 +1,362 @@
+// Package loggrpc provides the gRPC interceptors which install the
+// request-scoped loggers into the contexts and log the calls.
+package loggrpc
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/runner-mei/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key of the request id.
+const RequestIDKey = "x-request-id"
+
+// Config configures the interceptors.
+type Config struct {
+	// CodeToLevel returns the level of the entry of a call by its status
+	// code, default is DefaultCodeToLevel.
+	CodeToLevel func(code codes.Code) log.Level
+
+	// NewRequestID generates the id of the calls without one, default is
+	// log.NewRequestID.
+	NewRequestID func() string
+
+	// Skip reports whether the entry of the call of the full method is
+	// skipped, such as "/grpc.health.v1.Health/Check".
+	Skip func(fullMethod string) bool
+
+	// SpanLevel is the minimum level of the entries echoed into the span of
+	// the call, default is log.DefaultSpanLevel.
+	SpanLevel *log.Level
+
+	// Recoverer logs the panics of the server handlers, which are returned
+	// as codes.Internal errors unless RePanic is set, default is
+	// log.DefaultRecoverer.
+	Recoverer *log.Recoverer
+
+	// Message is the message of the entries of the calls, default is
+	// "grpc call".
+	Message string
+}
+
+// DefaultCodeToLevel logs the calls at InfoLevel for OK, at WarnLevel for the
+// client errors and at ErrorLevel for the server errors.
+func DefaultCodeToLevel(code codes.Code) log.Level {
+	switch code {
+	case codes.OK:
+		return log.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return log.WarnLevel
+	default:
+		return log.ErrorLevel
+	}
+}
+
+func (cfg *Config) init() {
+	if cfg.CodeToLevel == nil {
+		cfg.CodeToLevel = DefaultCodeToLevel
+	}
+	if cfg.NewRequestID == nil {
+		cfg.NewRequestID = log.NewRequestID
+	}
+	if cfg.SpanLevel == nil {
+		level := log.DefaultSpanLevel
+		cfg.SpanLevel = &level
+	}
+	if cfg.Recoverer == nil {
+		cfg.Recoverer = &log.DefaultRecoverer
+	}
+	if cfg.Message == "" {
+		cfg.Message = "grpc call"
+	}
+}
+
+// UnaryServerInterceptor returns a server interceptor which installs the
+// Logger and the method, peer and request_id fields into the context of the
+// call for log.For, and logs the call with its code and duration. The Logger
+// is logger, or the one in the context if logger is nil.
+func UnaryServerInterceptor(logger log.Logger, cfg Config) grpc.UnaryServerInterceptor {
+	cfg.init()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, id, finish := cfg.startServerCall(ctx, logger, info.FullMethod)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+		defer func() {
+			err = finish(recover(), err)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a server interceptor for the streams, see
+// UnaryServerInterceptor.
+func StreamServerInterceptor(logger log.Logger, cfg Config) grpc.StreamServerInterceptor {
+	cfg.init()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, id, finish := cfg.startServerCall(ss.Context(), logger, info.FullMethod)
+		ss.SetHeader(metadata.Pairs(RequestIDKey, id))
+		defer func() {
+			err = finish(recover(), err)
+		}()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+// startServerCall reads the request id and the trace of the incoming
+// metadata, and returns the context which holds the Logger, the fields and
+// the span of the call, so that log.For(ctx) binds the span once.
+// finish logs the call and converts the recovered panic into an error.
+func (cfg *Config) startServerCall(ctx context.Context, logger log.Logger, fullMethod string) (context.Context, string, func(recovered interface{}, err error) error) {
+	start := time.Now()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := first(md, RequestIDKey)
+	if id == "" || len(id) > 128 {
+		id = cfg.NewRequestID()
+	}
+	ctx = log.ContextWithRequestID(ctx, id)
+
+	if logger != nil {
+		ctx = log.ContextWithLogger(ctx, logger)
+	}
+	fields := []log.Field{log.String("method", fullMethod)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, log.String("peer", p.Addr.String()))
+	}
+	fields = append(fields, log.String("request_id", id))
+	ctx = log.ContextWithFields(ctx, fields...)
+
+	var span opentracing.Span
+	if opentracing.IsGlobalTracerRegistered() {
+		tracer := opentracing.GlobalTracer()
+		var opts []opentracing.StartSpanOption
+		if sc, err := tracer.Extract(opentracing.TextMap, metadataCarrier(md)); err == nil {
+			opts = append(opts, ext.RPCServerOption(sc))
+		} else {
+			opts = append(opts, ext.SpanKindRPCServer)
+		}
+		span = tracer.StartSpan(fullMethod, opts...)
+		ctx = opentracing.ContextWithSpan(ctx, span)
+	} else if log.OTelSpanFromContext(ctx) == nil {
+		if tc, ok := log.ExtractTraceContext(metadataHeader(md)); ok {
+			ctx = log.ContextWithTraceContext(ctx, tc)
+		}
+	}
+	ctx = log.ContextWithSpanLevel(ctx, *cfg.SpanLevel)
+	logger = log.For(ctx)
+
+	finish := func(recovered interface{}, err error) error {
+		if recovered != nil {
+			cfg.Recoverer.LogPanic(logger, recovered)
+			if cfg.Recoverer.RePanic {
+				finishSpan(span, codes.Internal)
+				panic(recovered)
+			}
+			err = status.Error(codes.Internal, "panic recovered")
+		}
+
+		code := status.Code(err)
+		finishSpan(span, code)
+		if cfg.Skip == nil || !cfg.Skip(fullMethod) {
+			cfg.logCall(logger, code, time.Since(start), err)
+		}
+		return err
+	}
+	return ctx, id, finish
+}
+
+// UnaryClientInterceptor returns a client interceptor which writes the
+// request id and the trace into the outgoing metadata, and logs the call
+// with its code and duration. The Logger is logger, or the one in the context
+// if logger is nil.
+func UnaryClientInterceptor(logger log.Logger, cfg Config) grpc.UnaryClientInterceptor {
+	cfg.init()
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, finish := cfg.startClientCall(ctx, logger, method)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finish(err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a client interceptor for the streams, the
+// call is logged when the stream ends, see UnaryClientInterceptor.
+func StreamClientInterceptor(logger log.Logger, cfg Config) grpc.StreamClientInterceptor {
+	cfg.init()
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, finish := cfg.startClientCall(ctx, logger, method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, desc: desc, finish: finish}, nil
+	}
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	desc     *grpc.StreamDesc
+	finish   func(err error)
+	finished bool
+}
+
+func (cs *clientStream) SendMsg(m interface{}) error {
+	err := cs.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		cs.end(err)
+	}
+	return err
+}
+
+func (cs *clientStream) RecvMsg(m interface{}) error {
+	err := cs.ClientStream.RecvMsg(m)
+	if err == io.EOF {
+		cs.end(nil)
+	} else if err != nil {
+		cs.end(err)
+	} else if !cs.desc.ServerStreams {
+		cs.end(nil)
+	}
+	return err
+}
+
+func (cs *clientStream) end(err error) {
+	if !cs.finished {
+		cs.finished = true
+		cs.finish(err)
+	}
+}
+
+// startClientCall writes the request id and the trace into the outgoing
+// metadata, and returns the function which logs the call.
+func (cfg *Config) startClientCall(ctx context.Context, logger log.Logger, method string) (context.Context, func(err error)) {
+	start := time.Now()
+
+	id := log.RequestIDFromContext(ctx)
+	if id == "" {
+		id = cfg.NewRequestID()
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(RequestIDKey, id)
+
+	if logger == nil {
+		logger = log.LoggerOrEmptyFromContext(ctx)
+	} else {
+		logger = logger.With(log.FieldsFromContext(ctx)...)
+	}
+	logger = logger.With(log.String("method", method))
+	if !hasField(log.FieldsFromContext(ctx), "request_id") {
+		logger = logger.With(log.String("request_id", id))
+	}
+
+	var span opentracing.Span
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		tracer := parent.Tracer()
+		span = tracer.StartSpan(method, opentracing.ChildOf(parent.Context()), ext.SpanKindRPCClient)
+		tracer.Inject(span.Context(), opentracing.TextMap, metadataCarrier(md))
+		logger = log.Span(logger, span, *cfg.SpanLevel)
+	} else if tc, ok := log.TraceContextFromContext(ctx); ok {
+		header := http.Header{}
+		log.InjectTraceContext(header, tc)
+		for key, values := range header {
+			md.Set(strings.ToLower(key), values...)
+		}
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return ctx, func(err error) {
+		code := status.Code(err)
+		finishSpan(span, code)
+		if cfg.Skip == nil || !cfg.Skip(method) {
+			cfg.logCall(logger, code, time.Since(start), err)
+		}
+	}
+}
+
+func (cfg *Config) logCall(logger log.Logger, code codes.Code, duration time.Duration, err error) {
+	fields := []log.Field{log.String("code", code.String()), log.Duration("duration", duration)}
+	if err != nil {
+		fields = append(fields, log.Error(err))
+	}
+
+	log.LogAt(logger.AddCallerSkip(1), cfg.CodeToLevel(code), cfg.Message, fields...)
+}
+
+func finishSpan(span opentracing.Span, code codes.Code) {
+	if span == nil {
+		return
+	}
+	span.SetTag("grpc.code", code.String())
+	if code != codes.OK {
+		ext.Error.Set(span, true)
+	}
+	span.Finish()
+}
+
+func hasField(fields []log.Field, key string) bool {
+	for _, field := range fields {
+		if field.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// metadataHeader returns the W3C trace context headers of the metadata.
+func metadataHeader(md metadata.MD) http.Header {
+	header := http.Header{}
+	for _, key := range []string{log.TraceParentHeader, log.TraceStateHeader, log.BaggageHeader} {
+		for _, value := range md.Get(key) {
+			header.Add(key, value)
+		}
+	}
+	return header
+}
+
+// metadataCarrier is the opentracing TextMap carrier of the metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Set(key, val string) {
+	metadata.MD(c).Set(key, val)
+}
+
+func (c metadataCarrier) ForeachKey(handler func(key, val string) error) error {
+	for key, values := range c {
+		for _, value := range values {
+			if err := handler(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
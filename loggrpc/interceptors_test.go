@@ -0,0 +1,186 @@
+package loggrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/runner-mei/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type healthServer struct {
+	*health.Server
+}
+
+func (s healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service == "debug" {
+		log.For(ctx).Debug("check", log.String("service", req.Service))
+	} else {
+		log.For(ctx).Info("check", log.String("service", req.Service))
+	}
+	if req.Service == "panic" {
+		panic("boom")
+	}
+	return s.Server.Check(ctx, req)
+}
+
+func dial(t *testing.T, serverLogger, clientLogger log.Logger) healthpb.HealthClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverLogger, Config{})),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverLogger, Config{})))
+	healthpb.RegisterHealthServer(server, healthServer{health.NewServer()})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientLogger, Config{})),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientLogger, Config{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	serverCore, serverLogs := observer.New(log.DebugLevel)
+	clientCore, clientLogs := observer.New(log.DebugLevel)
+	client := dial(t, log.NewLogger(zap.New(serverCore)), log.NewLogger(zap.New(clientCore)))
+
+	ctx := log.ContextWithRequestID(context.Background(), "abc")
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := serverLogs.TakeAll()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	fields := entries[0].ContextMap()
+	if entries[0].Message != "check" || fields["request_id"] != "abc" ||
+		fields["method"] != "/grpc.health.v1.Health/Check" || fields["peer"] == nil {
+		t.Error(fields)
+	}
+	fields = entries[1].ContextMap()
+	if entries[1].Level != log.InfoLevel || fields["code"] != "OK" || fields["duration"] == nil {
+		t.Error(fields)
+	}
+
+	entries = clientLogs.TakeAll()
+	if len(entries) != 1 || entries[0].ContextMap()["request_id"] != "abc" || entries[0].ContextMap()["code"] != "OK" {
+		t.Error(entries)
+	}
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatal(err)
+	}
+	if entries = serverLogs.TakeAll(); len(entries) != 2 || entries[1].Level != log.WarnLevel {
+		t.Error(entries)
+	}
+	if entries = clientLogs.TakeAll(); len(entries) != 1 || entries[0].Level != log.WarnLevel {
+		t.Error(entries)
+	}
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "panic"})
+	if status.Code(err) != codes.Internal {
+		t.Fatal(err)
+	}
+	entries = serverLogs.TakeAll()
+	if len(entries) != 3 || entries[1].ContextMap()["panic"] != "boom" ||
+		entries[2].Level != log.ErrorLevel || entries[2].ContextMap()["code"] != "Internal" {
+		t.Error(entries)
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	serverCore, serverLogs := observer.New(log.DebugLevel)
+	clientCore, clientLogs := observer.New(log.DebugLevel)
+	client := dial(t, log.NewLogger(zap.New(serverCore)), log.NewLogger(zap.New(clientCore)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatal(err)
+	}
+
+	entries := clientLogs.TakeAll()
+	if len(entries) != 1 || entries[0].ContextMap()["code"] != "Canceled" ||
+		entries[0].ContextMap()["method"] != "/grpc.health.v1.Health/Watch" {
+		t.Error(entries)
+	}
+	for i := 0; i < 100 && serverLogs.Len() == 0; i++ {
+		<-time.After(10 * time.Millisecond)
+	}
+	if entries = serverLogs.TakeAll(); len(entries) != 1 || entries[0].ContextMap()["request_id"] == nil {
+		t.Error(entries)
+	}
+}
+
+func TestUnaryServerInterceptorSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	serverCore, serverLogs := observer.New(log.DebugLevel)
+	client := dial(t, log.NewLogger(zap.New(serverCore)), log.Empty())
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := serverLogs.TakeAll()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if len(entry.Context) != len(fields) || fields["span_id"] == nil {
+			t.Error("duplicated fields", entry.Message, entry.Context)
+		}
+	}
+
+	var server *mocktracer.MockSpan
+	for _, span := range tracer.FinishedSpans() {
+		if span.Tag("span.kind") == ext.SpanKindRPCServerEnum {
+			server = span
+		}
+	}
+	if server == nil {
+		t.Fatal(tracer.FinishedSpans())
+	}
+	if records := server.Logs(); len(records) != 2 {
+		t.Error(records)
+	}
+
+	// the debug entries are echoed with the default span level
+	tracer.Reset()
+	client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "debug"})
+	if spans := tracer.FinishedSpans(); len(spans) != 1 || len(spans[0].Logs()) != 2 {
+		t.Error(spans)
+	}
+}
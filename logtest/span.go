@@ -0,0 +1,177 @@
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/runner-mei/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// the spans of the mock tracers are sampled unless their sampling
+	// priority is 0
+	log.RegisterSpanSampler(func(sc opentracing.SpanContext) (bool, bool) {
+		mock, ok := sc.(mocktracer.MockSpanContext)
+		return mock.Sampled, ok
+	})
+}
+
+// SpanRecord is a log record of a span, the event and level fields are
+// moved out of the fields.
+type SpanRecord struct {
+	Event  string
+	Level  string
+	Fields map[string]string
+}
+
+func (r SpanRecord) String() string {
+	return fmt.Sprintf("%s %q %v", r.Level, r.Event, r.Fields)
+}
+
+// ObservedSpan is a span of a mock tracer which records the entries echoed
+// into it by the span-aware loggers.
+type ObservedSpan struct {
+	t      testing.TB
+	Tracer *mocktracer.MockTracer
+	Span   *mocktracer.MockSpan
+}
+
+// NewSpan starts a span of a new mock tracer.
+func NewSpan(t testing.TB, operationName string) *ObservedSpan {
+	tracer := mocktracer.New()
+	return &ObservedSpan{
+		t:      t,
+		Tracer: tracer,
+		Span:   tracer.StartSpan(operationName).(*mocktracer.MockSpan),
+	}
+}
+
+// NewSpanLogger starts a span of a new mock tracer, and returns a Logger of
+// log.Span which echoes the entries of logger into it.
+func NewSpanLogger(t testing.TB, logger log.Logger, enabledLevel ...log.Level) (log.Logger, *ObservedSpan) {
+	span := NewSpan(t, t.Name())
+	return log.Span(logger, span.Span, enabledLevel...), span
+}
+
+// NewSpanContext starts a span of a new mock tracer, and returns a ctx which
+// holds both the span and logger, so that log.For(ctx) echoes the entries
+// into the span.
+func NewSpanContext(t testing.TB, ctx context.Context, logger log.Logger) (context.Context, *ObservedSpan) {
+	span := NewSpan(t, t.Name())
+	ctx = opentracing.ContextWithSpan(ctx, span.Span)
+	return log.ContextWithLogger(ctx, logger), span
+}
+
+// Records returns the log records of the span.
+func (s *ObservedSpan) Records() []SpanRecord {
+	logs := s.Span.Logs()
+	records := make([]SpanRecord, 0, len(logs))
+	for _, l := range logs {
+		record := SpanRecord{Fields: map[string]string{}}
+		for _, kv := range l.Fields {
+			switch kv.Key {
+			case "event":
+				record.Event = kv.ValueString
+			case "level":
+				record.Level = kv.ValueString
+			default:
+				record.Fields[kv.Key] = kv.ValueString
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// AssertRecord asserts that the span has a record of the event at the level,
+// which contains the fields. The failure shows the diff to the record of the
+// event, or all records if there isn't one.
+//
+// The event of an entry with an error is "error" and its message is the
+// "message" field, such as
+//
+//	span.AssertRecord("error", log.ErrorLevel, map[string]string{"message": "query failed"})
+func (s *ObservedSpan) AssertRecord(event string, level log.Level, fields map[string]string) bool {
+	s.t.Helper()
+
+	records := s.Records()
+	var candidates []SpanRecord
+	for _, record := range records {
+		if record.Event != event {
+			continue
+		}
+		if record.Level == level.String() && containsFields(record.Fields, fields) {
+			return true
+		}
+		candidates = append(candidates, record)
+	}
+
+	expected := SpanRecord{Event: event, Level: level.String(), Fields: fields}
+	if len(candidates) == 0 {
+		return assert.Fail(s.t, "span record not found",
+			"expected: %s\nrecords:\n%s", expected, formatRecords(records))
+	}
+	actual := candidates[0]
+	actual.Fields = selectFields(actual.Fields, fields)
+	if expected.Fields == nil {
+		expected.Fields = map[string]string{}
+	}
+	return assert.Equal(s.t, expected, actual, "span record of %q", event)
+}
+
+// AssertNoRecords asserts that nothing is echoed into the span.
+func (s *ObservedSpan) AssertNoRecords() bool {
+	s.t.Helper()
+	if records := s.Records(); len(records) > 0 {
+		return assert.Fail(s.t, "span has records", "records:\n%s", formatRecords(records))
+	}
+	return true
+}
+
+// AssertError asserts whether the error tag of the span is set.
+func (s *ObservedSpan) AssertError(failed bool) bool {
+	s.t.Helper()
+	value, _ := s.Span.Tag("error").(bool)
+	return assert.Equal(s.t, failed, value, "error tag of span %q", s.Span.OperationName)
+}
+
+func containsFields(actual, expected map[string]string) bool {
+	for key, value := range expected {
+		if v, ok := actual[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// selectFields returns the actual fields which are expected, and the fields
+// which are missing are omitted so that the diff shows them.
+func selectFields(actual, expected map[string]string) map[string]string {
+	results := map[string]string{}
+	for key := range expected {
+		if value, ok := actual[key]; ok {
+			results[key] = value
+		}
+	}
+	return results
+}
+
+func formatRecords(records []SpanRecord) string {
+	if len(records) == 0 {
+		return "\t(none)"
+	}
+	var sb strings.Builder
+	for idx, record := range records {
+		if idx > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteByte('\t')
+		sb.WriteString(record.String())
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package logtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/runner-mei/log"
+)
+
+// fakeTB records the failures instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	failures []string
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Name() string {
+	return "fake"
+}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) assertFailed(tt *testing.T, ok bool, snippets ...string) {
+	tt.Helper()
+	if ok || len(t.failures) != 1 {
+		tt.Fatal(ok, t.failures)
+	}
+	for _, snippet := range snippets {
+		if !strings.Contains(t.failures[0], snippet) {
+			tt.Errorf("%q isn't in %s", snippet, t.failures[0])
+		}
+	}
+	t.failures = nil
+}
+
+func TestObservedSpan(t *testing.T) {
+	fake := &fakeTB{}
+	logger, span := NewSpanLogger(fake, log.Empty())
+	if !span.AssertNoRecords() || !span.AssertError(false) {
+		t.Error(fake.failures)
+	}
+
+	logger.Info("query", log.String("table", "users"))
+	logger.Error("query failed", log.Error(errors.New("boom")))
+	span.Span.Finish()
+
+	if !span.AssertRecord("query", log.InfoLevel, map[string]string{"table": "users"}) ||
+		!span.AssertRecord("query", log.InfoLevel, nil) ||
+		!span.AssertRecord("error", log.ErrorLevel, map[string]string{"message": "query failed"}) ||
+		!span.AssertError(true) {
+		t.Error(fake.failures)
+	}
+	if len(fake.failures) != 0 {
+		t.Error(fake.failures)
+	}
+
+	fake.assertFailed(t, span.AssertRecord("missing", log.InfoLevel, nil),
+		"span record not found", `info "query" map[table:users]`)
+	fake.assertFailed(t, span.AssertRecord("query", log.WarnLevel, map[string]string{"table": "users"}),
+		`span record of "query"`, `"warn"`)
+	fake.assertFailed(t, span.AssertRecord("query", log.InfoLevel, map[string]string{"table": "jobs", "id": "1"}),
+		`span record of "query"`, `(string) (len=2) "id": (string) (len=1) "1"`)
+	fake.assertFailed(t, span.AssertNoRecords(), "span has records", `"query"`)
+	fake.assertFailed(t, span.AssertError(false), `error tag of span "fake"`)
+}
+
+func TestNewSpanContext(t *testing.T) {
+	ctx, span := NewSpanContext(t, context.Background(), log.Empty())
+	log.For(ctx).Info("handle")
+	span.AssertRecord("handle", log.InfoLevel, nil)
+	if records := span.Records(); len(records) != 1 {
+		t.Error(records)
+	}
+}
@@ -0,0 +1,142 @@
+package log
+
+import (
+	"strings"
+	"sync"
+)
+
+// Locale is the table of the level prefixes of the user-facing messages, the
+// messages of the levels which aren't in it have no prefix.
+type Locale map[Level]string
+
+// The built-in locales.
+var (
+	LocaleZhCN = Locale{
+		InfoLevel:   "信息：",
+		WarnLevel:   "警告：",
+		ErrorLevel:  "错误：",
+		DPanicLevel: "异常：",
+		PanicLevel:  "异常：",
+		FatalLevel:  "致命错误：",
+	}
+
+	LocaleEnUS = Locale{
+		InfoLevel:   "Info: ",
+		WarnLevel:   "Warning: ",
+		ErrorLevel:  "Error: ",
+		DPanicLevel: "Exception: ",
+		PanicLevel:  "Exception: ",
+		FatalLevel:  "Fatal error: ",
+	}
+)
+
+// DefaultLocale is the locale of OutputToStrings and of the languages which
+// aren't registered.
+var DefaultLocale = LocaleZhCN
+
+var (
+	localesLock sync.RWMutex
+	locales     = map[string]Locale{
+		"zh-cn": LocaleZhCN,
+		"en-us": LocaleEnUS,
+	}
+)
+
+// RegisterLocale adds or replaces the locale of the language tag, such as
+// "ja-JP" or "de".
+func RegisterLocale(lang string, locale Locale) {
+	localesLock.Lock()
+	locales[strings.ToLower(lang)] = locale
+	localesLock.Unlock()
+}
+
+// LookupLocale returns the locale of the language tag, which falls back to
+// the base language, such as "en-GB" to "en" or any "en-*" locale, and then
+// to DefaultLocale.
+func LookupLocale(lang string) Locale {
+	lang = strings.ToLower(strings.Replace(lang, "_", "-", -1))
+
+	localesLock.RLock()
+	defer localesLock.RUnlock()
+
+	if locale, ok := locales[lang]; ok {
+		return locale
+	}
+	base := lang
+	if idx := strings.IndexByte(lang, '-'); idx >= 0 {
+		base = lang[:idx]
+	}
+	if locale, ok := locales[base]; ok {
+		return locale
+	}
+	var found string
+	for name := range locales {
+		if strings.HasPrefix(name, base+"-") && (found == "" || name < found) {
+			found = name
+		}
+	}
+	if found != "" {
+		return locales[found]
+	}
+	return DefaultLocale
+}
+
+// Render returns the message with the prefix of the level.
+func (l Locale) Render(level Level, text string) string {
+	return l[level] + text
+}
+
+// Message is a collected user-facing message.
+type Message struct {
+	Level Level
+	Text  string
+}
+
+// Messages collects the user-facing messages with their levels, so that they
+// are rendered on demand in the language of the user who views them, it is
+// safe for concurrent use.
+type Messages struct {
+	enabledLevel Level
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMessages returns a Messages which collects the messages of the levels
+// enabled by enabledLevel.
+func NewMessages(enabledLevel Level) *Messages {
+	return &Messages{enabledLevel: enabledLevel}
+}
+
+// LogFields collects the message, the fields are ignored.
+func (m *Messages) LogFields(level Level, msg string, fields ...Field) {
+	if !m.enabledLevel.Enabled(level) {
+		return
+	}
+	m.mu.Lock()
+	m.messages = append(m.messages, Message{Level: level, Text: msg})
+	m.mu.Unlock()
+}
+
+// All returns the collected messages.
+func (m *Messages) All() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Render returns the collected messages with the prefixes of the locale.
+func (m *Messages) Render(locale Locale) []string {
+	messages := m.All()
+	results := make([]string, 0, len(messages))
+	for _, message := range messages {
+		results = append(results, locale.Render(message.Level, message.Text))
+	}
+	return results
+}
+
+// RenderLang returns the collected messages with the prefixes of the locale
+// of the language tag, see LookupLocale.
+func (m *Messages) RenderLang(lang string) []string {
+	return m.Render(LookupLocale(lang))
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	messages := NewMessages(InfoLevel)
+	logger := Empty().WithTargets(messages)
+	logger.Debug("skipped")
+	logger.Info("started")
+	logger.Warn("slow")
+	logger.Error("failed")
+
+	if rendered := messages.RenderLang("en-US"); !reflect.DeepEqual(rendered,
+		[]string{"Info: started", "Warning: slow", "Error: failed"}) {
+		t.Error(rendered)
+	}
+	if rendered := messages.RenderLang("en_GB"); rendered[0] != "Info: started" {
+		t.Error(rendered)
+	}
+	if rendered := messages.RenderLang("zh-CN"); rendered[2] != "错误：failed" {
+		t.Error(rendered)
+	}
+
+	RegisterLocale("fr", Locale{ErrorLevel: "Erreur : "})
+	defer func() {
+		localesLock.Lock()
+		delete(locales, "fr")
+		localesLock.Unlock()
+	}()
+	if rendered := messages.RenderLang("fr-FR"); rendered[0] != "started" || rendered[2] != "Erreur : failed" {
+		t.Error(rendered)
+	}
+	if rendered := messages.RenderLang("unknown"); rendered[1] != "警告：slow" {
+		t.Error(rendered)
+	}
+
+	var target []string
+	Empty().WithTargets(OutputToStrings(InfoLevel, &target)).Warn("slow")
+	if len(target) != 1 || target[0] != "警告：slow" {
+		t.Error(target)
+	}
+}
@@ -0,0 +1,267 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultMaxSeries is the default cap on the number of label combinations
+// kept by Metrics.
+const DefaultMaxSeries = 1000
+
+// overflowLabel replaces the label values of entries that would exceed MaxSeries.
+const overflowLabel = "_other_"
+
+// MetricsConfig configures Metrics.
+type MetricsConfig struct {
+	// Namespace is prefixed to the metric names, default is "log".
+	Namespace string
+
+	// Field is the name of an optional field such as "error_code" whose value
+	// becomes an extra label.
+	Field string
+
+	// MaxSeries caps the number of distinct label combinations, entries beyond
+	// the cap are counted with the logger and field labels set to "_other_".
+	MaxSeries int
+}
+
+type seriesKey struct {
+	level  Level
+	logger string
+	field  string
+}
+
+// Metrics counts log entries by level, logger name and optionally the value
+// of a configured field, and exposes them in the Prometheus text exposition
+// format.
+type Metrics struct {
+	namespace string
+	field     string
+	maxSeries int
+
+	mu     sync.Mutex
+	series map[seriesKey]uint64
+	gauges []gauge
+}
+
+type gauge struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+// NewMetrics creates a Metrics with the given config.
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "log"
+	}
+	if cfg.MaxSeries <= 0 {
+		cfg.MaxSeries = DefaultMaxSeries
+	}
+	return &Metrics{
+		namespace: cfg.Namespace,
+		field:     cfg.Field,
+		maxSeries: cfg.MaxSeries,
+		series:    map[seriesKey]uint64{},
+	}
+}
+
+func (m *Metrics) observe(level Level, name, fieldValue string) {
+	key := seriesKey{level: level, logger: name, field: fieldValue}
+
+	m.mu.Lock()
+	if _, ok := m.series[key]; !ok && len(m.series) >= m.maxSeries {
+		key.logger = overflowLabel
+		if m.field != "" {
+			key.field = overflowLabel
+		}
+	}
+	m.series[key]++
+	m.mu.Unlock()
+}
+
+// fieldValue returns the value of the configured field in fields, or "".
+func (m *Metrics) fieldValue(fields []Field) (string, bool) {
+	if m.field == "" {
+		return "", false
+	}
+	for idx := len(fields) - 1; idx >= 0; idx-- {
+		if fields[idx].Key == m.field {
+			return fieldString(fields[idx]), true
+		}
+	}
+	return "", false
+}
+
+func fieldString(field Field) string {
+	switch field.Type {
+	case zapcore.StringType:
+		return field.String
+	case zapcore.StringerType:
+		return fmt.Sprint(field.Interface)
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && err != nil {
+			return err.Error()
+		}
+		return ""
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	return fmt.Sprint(enc.Fields[field.Key])
+}
+
+// LogFields counts the entry under an empty logger name, so that Metrics can
+// be used as a Target.
+func (m *Metrics) LogFields(level Level, msg string, fields ...Field) {
+	value, _ := m.fieldValue(fields)
+	m.observe(level, "", value)
+}
+
+// Target returns a Target which counts entries under the given logger name.
+func (m *Metrics) Target(name string) Target {
+	return Callback(func(level Level, msg string, fields ...Field) {
+		value, _ := m.fieldValue(fields)
+		m.observe(level, name, value)
+	})
+}
+
+// WrapCore wraps a zapcore.Core so that every entry written to it is counted,
+// it can be used with zap.WrapCore.
+func (m *Metrics) WrapCore(core zapcore.Core) zapcore.Core {
+	return metricsCore{Core: core, metrics: m}
+}
+
+// Gauge adds a gauge whose value is read by value on every scrape, the name
+// is prefixed with the namespace.
+func (m *Metrics) Gauge(name, help string, value func() float64) {
+	m.mu.Lock()
+	m.gauges = append(m.gauges, gauge{name: m.namespace + "_" + name, help: help, value: value})
+	m.mu.Unlock()
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	gauges := m.gauges
+	keys := make([]seriesKey, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	values := make([]uint64, len(keys))
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].logger != keys[j].logger {
+			return keys[i].logger < keys[j].logger
+		}
+		if keys[i].level != keys[j].level {
+			return keys[i].level < keys[j].level
+		}
+		return keys[i].field < keys[j].field
+	})
+	for idx, key := range keys {
+		values[idx] = m.series[key]
+	}
+	m.mu.Unlock()
+
+	name := m.namespace + "_entries_total"
+
+	var sb strings.Builder
+	sb.WriteString("# HELP " + name + " Number of log entries by level and logger.\n")
+	sb.WriteString("# TYPE " + name + " counter\n")
+	for idx, key := range keys {
+		sb.WriteString(name)
+		sb.WriteString(`{level="`)
+		sb.WriteString(key.level.String())
+		sb.WriteString(`",logger="`)
+		sb.WriteString(escapeLabelValue(key.logger))
+		if m.field != "" {
+			sb.WriteString(`",`)
+			sb.WriteString(labelName(m.field))
+			sb.WriteString(`="`)
+			sb.WriteString(escapeLabelValue(key.field))
+		}
+		sb.WriteString(`"} `)
+		fmt.Fprint(&sb, values[idx])
+		sb.WriteString("\n")
+	}
+	for _, g := range gauges {
+		sb.WriteString("# HELP " + g.name + " " + g.help + "\n")
+		sb.WriteString("# TYPE " + g.name + " gauge\n")
+		sb.WriteString(g.name + " " + strconv.FormatFloat(g.value(), 'g', -1, 64) + "\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ServeHTTP implements http.Handler.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+// labelName converts a field name into a valid prometheus label name.
+func labelName(s string) string {
+	var sb strings.Builder
+	for idx, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			sb.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if idx == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+type metricsCore struct {
+	zapcore.Core
+	metrics *Metrics
+
+	// fieldValue is the value of the configured field added by With
+	fieldValue string
+}
+
+func (c metricsCore) With(fields []Field) zapcore.Core {
+	value := c.fieldValue
+	if v, ok := c.metrics.fieldValue(fields); ok {
+		value = v
+	}
+	return metricsCore{Core: c.Core.With(fields), metrics: c.metrics, fieldValue: value}
+}
+
+func (c metricsCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	// like zap.Hooks, let the wrapped core decide and only count the entries it accepts
+	if downstream := c.Core.Check(ent, ce); downstream != nil {
+		return downstream.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write only counts the entry, the wrapped core is written by the CheckedEntry.
+func (c metricsCore) Write(ent zapcore.Entry, fields []Field) error {
+	value := c.fieldValue
+	if v, ok := c.metrics.fieldValue(fields); ok {
+		value = v
+	}
+	c.metrics.observe(ent.Level, ent.LoggerName, value)
+	return nil
+}
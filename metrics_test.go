@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMetricsCore(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{Field: "error_code"})
+
+	core, logs := observer.New(InfoLevel)
+	logger := NewLogger(zap.New(metrics.WrapCore(core)))
+
+	logger.Debug("skipped")
+	logger.Info("a")
+	logger.Named("db").Error("b", String("error_code", "E1"))
+	logger.Named("db").With(String("error_code", "E2")).Error("c")
+	logger.Named("db").With(String("error_code", "E2")).Error("d")
+
+	if logs.Len() != 4 {
+		t.Fatal("want 4 entries, got", logs.Len())
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	excepted := `# HELP log_entries_total Number of log entries by level and logger.
+# TYPE log_entries_total counter
+log_entries_total{level="info",logger="",error_code=""} 1
+log_entries_total{level="error",logger="db",error_code="E1"} 1
+log_entries_total{level="error",logger="db",error_code="E2"} 2
+`
+	if actual := rec.Body.String(); actual != excepted {
+		t.Error("excepted", excepted)
+		t.Error("actual  ", actual)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Error("content type is", ct)
+	}
+}
+
+func TestMetricsMaxSeries(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{Namespace: "app", MaxSeries: 2})
+
+	logger := Empty().WithTargets(metrics.Target("a\"b"))
+	logger.Error("x", Error(errors.New("abc")))
+	logger.Info("x")
+	metrics.Target("c").LogFields(WarnLevel, "y")
+	metrics.Target("d").LogFields(WarnLevel, "z")
+
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	excepted := `# HELP app_entries_total Number of log entries by level and logger.
+# TYPE app_entries_total counter
+app_entries_total{level="warn",logger="_other_"} 2
+app_entries_total{level="info",logger="a\"b"} 1
+app_entries_total{level="error",logger="a\"b"} 1
+`
+	if actual := buf.String(); actual != excepted {
+		t.Error("excepted", excepted)
+		t.Error("actual  ", actual)
+	}
+}
@@ -0,0 +1,56 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// An Option configures the Logger built by NewLogger, New, NewFileWithOptions,
+// NewZapLogger and NewDebugZapLogger.
+type Option interface {
+	apply(*options)
+}
+
+type options struct {
+	wrappers []func(zapcore.Core) zapcore.Core
+}
+
+// optionFunc wraps a func so it satisfies the Option interface.
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt.apply(o)
+	}
+	return o
+}
+
+func (o *options) zapOptions() []zap.Option {
+	if len(o.wrappers) == 0 {
+		return nil
+	}
+	return []zap.Option{zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		for _, wrap := range o.wrappers {
+			core = wrap(core)
+		}
+		return core
+	})}
+}
+
+// WrapCore wraps the zapcore.Core of the Logger, the wrappers are applied in
+// order, so the last one is the outermost.
+func WrapCore(wrap func(zapcore.Core) zapcore.Core) Option {
+	return optionFunc(func(o *options) {
+		o.wrappers = append(o.wrappers, wrap)
+	})
+}
+
+// WithRateLimit limits the entries of the Logger with a RateLimiter.
+func WithRateLimit(limiter *RateLimiter) Option {
+	return WrapCore(limiter.WrapCore)
+}
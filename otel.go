@@ -0,0 +1,116 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/opentracing/opentracing-go/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTelTracerName is the name of the OpenTelemetry tracer which starts the
+// spans of OTelSpanContext and StartSpan.
+const OTelTracerName = "github.com/runner-mei/log"
+
+// OTelSpanFromContext returns the OpenTelemetry span in the ctx, or nil if
+// there isn't a valid one.
+func OTelSpanFromContext(ctx context.Context) trace.Span {
+	span := trace.SpanFromContext(ctx)
+	if !span.SpanContext().IsValid() {
+		return nil
+	}
+	return span
+}
+
+// OutputToOTel echoes the entries as events of an OpenTelemetry span.
+func OutputToOTel(enabledLevel Level, span trace.Span) Callback {
+	return Callback(func(level Level, msg string, fields ...Field) {
+		if !span.IsRecording() {
+			return
+		}
+		failed := SpanErrorLevel.Enabled(level)
+		if failed {
+			span.SetStatus(codes.Error, msg)
+		}
+		if !enabledLevel.Enabled(level) {
+			return
+		}
+		attrs := otelAttributes(fields, attribute.String("level", level.String()))
+		span.AddEvent(msg, trace.WithAttributes(attrs...))
+
+		if err := errorOf(fields); failed && err != nil {
+			span.RecordError(err, trace.WithAttributes(attribute.String("exception.stacktrace", takeStack())))
+		}
+	})
+}
+
+// otelAttributes converts the zap fields into OpenTelemetry attributes like
+// OutputToTracer, the nested objects and namespaces are flattened with dotted
+// keys, the arrays are JSON-encoded.
+func otelAttributes(fields []Field, attrs ...attribute.KeyValue) []attribute.KeyValue {
+	fa := &fieldAdapter{fields: make([]log.Field, 0, len(fields))}
+	for _, field := range fields {
+		field.AddTo(fa)
+	}
+	ae := attributeEncoder(attrs)
+	for _, field := range fa.fields {
+		field.Marshal(&ae)
+	}
+	return ae
+}
+
+// attributeEncoder converts the opentracing log fields into OpenTelemetry
+// attributes.
+type attributeEncoder []attribute.KeyValue
+
+func (ae *attributeEncoder) EmitString(key, value string) {
+	*ae = append(*ae, attribute.String(key, value))
+}
+
+func (ae *attributeEncoder) EmitBool(key string, value bool) {
+	*ae = append(*ae, attribute.Bool(key, value))
+}
+
+func (ae *attributeEncoder) EmitInt(key string, value int) {
+	*ae = append(*ae, attribute.Int(key, value))
+}
+
+func (ae *attributeEncoder) EmitInt32(key string, value int32) {
+	*ae = append(*ae, attribute.Int64(key, int64(value)))
+}
+
+func (ae *attributeEncoder) EmitInt64(key string, value int64) {
+	*ae = append(*ae, attribute.Int64(key, value))
+}
+
+func (ae *attributeEncoder) EmitUint32(key string, value uint32) {
+	*ae = append(*ae, attribute.Int64(key, int64(value)))
+}
+
+func (ae *attributeEncoder) EmitUint64(key string, value uint64) {
+	if value > math.MaxInt64 {
+		*ae = append(*ae, attribute.String(key, strconv.FormatUint(value, 10)))
+		return
+	}
+	*ae = append(*ae, attribute.Int64(key, int64(value)))
+}
+
+func (ae *attributeEncoder) EmitFloat32(key string, value float32) {
+	*ae = append(*ae, attribute.Float64(key, float64(value)))
+}
+
+func (ae *attributeEncoder) EmitFloat64(key string, value float64) {
+	*ae = append(*ae, attribute.Float64(key, value))
+}
+
+func (ae *attributeEncoder) EmitObject(key string, value interface{}) {
+	*ae = append(*ae, attribute.String(key, fmt.Sprint(value)))
+}
+
+func (ae *attributeEncoder) EmitLazyLogger(value log.LazyLogger) {
+	value(ae)
+}
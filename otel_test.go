@@ -0,0 +1,122 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type recordedEvent struct {
+	name  string
+	attrs map[attribute.Key]attribute.Value
+}
+
+// recordingSpan is a trace.Span which records the events, the status and the
+// errors.
+type recordingSpan struct {
+	trace.Span
+	sc trace.SpanContext
+
+	mu          sync.Mutex
+	events      []recordedEvent
+	status      codes.Code
+	description string
+	errs        []error
+}
+
+func newRecordingSpan() *recordingSpan {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	return &recordingSpan{Span: trace.SpanFromContext(context.Background()), sc: sc}
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext { return s.sc }
+func (s *recordingSpan) IsRecording() bool              { return true }
+
+func (s *recordingSpan) AddEvent(name string, options ...trace.EventOption) {
+	attrs := map[attribute.Key]attribute.Value{}
+	cfg := trace.NewEventConfig(options...)
+	for _, kv := range cfg.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	s.mu.Lock()
+	s.events = append(s.events, recordedEvent{name: name, attrs: attrs})
+	s.mu.Unlock()
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	s.status, s.description = code, description
+	s.mu.Unlock()
+}
+
+func (s *recordingSpan) RecordError(err error, options ...trace.EventOption) {
+	s.mu.Lock()
+	s.errs = append(s.errs, err)
+	s.mu.Unlock()
+}
+
+func TestOutputToOTel(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+	span := newRecordingSpan()
+	ctx := trace.ContextWithSpan(context.Background(), span)
+
+	For(ctx, logger).Info("query", String("table", "users"))
+	SpanFromContext(ctx, logger).Debug("debug")
+	OTelSpan(logger, span, WarnLevel).Info("skipped")
+	For(CloneContext(ctx, context.Background()), logger).Error("failed", Error(errors.New("boom")))
+
+	if len(span.events) != 3 {
+		t.Fatal(span.events)
+	}
+	query := span.events[0]
+	if query.name != "query" || query.attrs["level"].AsString() != "info" || query.attrs["table"].AsString() != "users" {
+		t.Error(query)
+	}
+	if span.events[1].name != "debug" || span.events[2].name != "failed" {
+		t.Error(span.events)
+	}
+	if span.status != codes.Error || span.description != "failed" {
+		t.Error(span.status, span.description)
+	}
+	if len(span.errs) != 1 || span.errs[0].Error() != "boom" {
+		t.Error(span.errs)
+	}
+
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if fields["trace_id"] != span.sc.TraceID().String() || fields["span_id"] != span.sc.SpanID().String() {
+			t.Error(entry.Message, fields)
+		}
+	}
+	if n := logs.Len(); n != 4 {
+		t.Error(n)
+	}
+}
+
+func TestOTelSpanContext(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	parent := newRecordingSpan().SpanContext()
+
+	logger, finish := OTelSpanContext(NewLogger(zap.New(core)), parent, "child")
+	logger.Info("child")
+	finish()
+
+	if fields := logs.All()[0].ContextMap(); fields["trace_id"] != parent.TraceID().String() {
+		t.Error(fields)
+	}
+	if _, finish := OTelSpanContext(Empty(), trace.SpanContext{}, "invalid"); finish == nil {
+		t.Error("finish is nil")
+	}
+}
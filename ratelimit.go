@@ -0,0 +1,258 @@
+package log
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultRateLimitReportInterval is the default interval in which the dropped
+// entries are reported.
+const DefaultRateLimitReportInterval = time.Minute
+
+// DefaultRateLimitMaxKeys is the default cap on the number of token buckets.
+const DefaultRateLimitMaxKeys = 10000
+
+// Limit is a token bucket limit, Rate tokens are added per second up to
+// Burst. The zero Limit means unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0 && l.Burst <= 0
+}
+
+// KeyByLogger limits the entries per logger name.
+func KeyByLogger(ent zapcore.Entry) string {
+	return ent.LoggerName
+}
+
+// KeyByMessage limits the entries per message and caller.
+func KeyByMessage(ent zapcore.Entry) string {
+	if ent.Caller.Defined {
+		return ent.Caller.String() + " " + ent.Message
+	}
+	return ent.Message
+}
+
+// RateLimitConfig configures RateLimiter.
+type RateLimitConfig struct {
+	// Key returns the key of a bucket, default is KeyByLogger.
+	Key func(ent zapcore.Entry) string
+
+	// Limit is the limit of the entries of a key, such as at most Rate
+	// entries per second of a logger by KeyByLogger, which the levels that
+	// aren't in Levels share.
+	Limit Limit
+
+	// Levels overrides Limit per level, each of them has its own buckets.
+	// DPanic, Panic and Fatal are never limited unless they are in Levels.
+	Levels map[Level]Limit
+
+	// ReportInterval is the interval in which the dropped entries are
+	// reported, default is DefaultRateLimitReportInterval.
+	ReportInterval time.Duration
+
+	// MaxKeys caps the number of buckets, default is DefaultRateLimitMaxKeys.
+	MaxKeys int
+}
+
+// RateLimiter limits log entries with token buckets, it counts the dropped
+// entries and reports them at WarnLevel periodically.
+type RateLimiter struct {
+	key            func(ent zapcore.Entry) string
+	limits         [FatalLevel - DebugLevel + 1]Limit
+	leveled        [FatalLevel - DebugLevel + 1]bool
+	reportInterval time.Duration
+	maxKeys        int
+
+	mu           sync.Mutex
+	buckets      map[bucketKey]*bucket
+	total        uint64
+	dropped      map[string]uint64
+	reportTimer  *time.Timer
+	reportTarget func(Level, string, ...Field)
+}
+
+// bucketKey is the key of a bucket, level is only set for the levels which
+// are in RateLimitConfig.Levels.
+type bucketKey struct {
+	leveled bool
+	level   Level
+	key     string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter with the given config.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	r := &RateLimiter{
+		key:            cfg.Key,
+		reportInterval: cfg.ReportInterval,
+		maxKeys:        cfg.MaxKeys,
+		buckets:        map[bucketKey]*bucket{},
+		dropped:        map[string]uint64{},
+	}
+	if r.key == nil {
+		r.key = KeyByLogger
+	}
+	if r.reportInterval <= 0 {
+		r.reportInterval = DefaultRateLimitReportInterval
+	}
+	if r.maxKeys <= 0 {
+		r.maxKeys = DefaultRateLimitMaxKeys
+	}
+	for lvl := DebugLevel; lvl <= FatalLevel; lvl++ {
+		limit, ok := cfg.Levels[lvl]
+		if !ok {
+			if lvl >= DPanicLevel {
+				continue
+			}
+			limit = cfg.Limit
+		}
+		r.leveled[lvl-DebugLevel] = ok
+		if limit.Rate > 0 && limit.Burst < 1 {
+			limit.Burst = 1
+		}
+		r.limits[lvl-DebugLevel] = limit
+	}
+	return r
+}
+
+func (r *RateLimiter) limit(level Level) Limit {
+	if level < DebugLevel || level > FatalLevel {
+		return Limit{}
+	}
+	return r.limits[level-DebugLevel]
+}
+
+// allow reports whether the entry may be written, report writes the report of
+// the dropped entries.
+func (r *RateLimiter) allow(ent zapcore.Entry, report func(Level, string, ...Field)) bool {
+	limit := r.limit(ent.Level)
+	if limit.unlimited() {
+		return true
+	}
+
+	key := r.key(ent)
+	bk := bucketKey{key: key}
+	if r.leveled[ent.Level-DebugLevel] {
+		bk.leveled, bk.level = true, ent.Level
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := r.buckets[bk]
+	if b == nil {
+		if len(r.buckets) >= r.maxKeys {
+			r.buckets = map[bucketKey]*bucket{}
+		}
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		r.buckets[bk] = b
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * limit.Rate
+		if burst := float64(limit.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+
+	r.total++
+	r.dropped[key]++
+	r.reportTarget = report
+	if r.reportTimer == nil {
+		r.reportTimer = time.AfterFunc(r.reportInterval, r.report)
+	}
+	return false
+}
+
+// Dropped returns the number of the dropped entries since the limiter is created.
+func (r *RateLimiter) Dropped() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+func (r *RateLimiter) report() {
+	r.mu.Lock()
+	dropped := r.dropped
+	report := r.reportTarget
+	r.dropped = map[string]uint64{}
+	r.reportTarget = nil
+	r.reportTimer = nil
+	r.mu.Unlock()
+
+	var count uint64
+	for _, n := range dropped {
+		count += n
+	}
+	if count == 0 || report == nil {
+		return
+	}
+	report(WarnLevel, "log entries dropped by rate limit",
+		Uint64("dropped", count),
+		Duration("interval", r.reportInterval),
+		Any("keys", dropped))
+}
+
+// Target returns a Target which writes to out with the entries limited, the
+// entries are keyed as if they were logged by the named logger.
+func (r *RateLimiter) Target(name string, out Target) Target {
+	return Callback(func(level Level, msg string, fields ...Field) {
+		if r.allow(zapcore.Entry{Level: level, LoggerName: name, Message: msg}, out.LogFields) {
+			out.LogFields(level, msg, fields...)
+		}
+	})
+}
+
+// WrapCore wraps a zapcore.Core so that the entries are limited, it can be
+// used with zap.WrapCore or WithRateLimit.
+func (r *RateLimiter) WrapCore(core zapcore.Core) zapcore.Core {
+	return rateLimitCore{Core: core, limiter: r}
+}
+
+type rateLimitCore struct {
+	zapcore.Core
+	limiter *RateLimiter
+}
+
+func (c rateLimitCore) With(fields []Field) zapcore.Core {
+	return rateLimitCore{Core: c.Core.With(fields), limiter: c.limiter}
+}
+
+func (c rateLimitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write drops the entry over the limit, the others are written through the
+// Check of the wrapped core, so that its sampling and level filtering apply.
+func (c rateLimitCore) Write(ent zapcore.Entry, fields []Field) error {
+	if !c.limiter.allow(ent, c.report) {
+		return nil
+	}
+	return checkWrite(c.Core, ent, fields)
+}
+
+func (c rateLimitCore) report(level Level, msg string, fields ...Field) {
+	checkWrite(c.Core, zapcore.Entry{
+		Level:   level,
+		Time:    time.Now(),
+		Message: msg,
+	}, fields)
+}
@@ -0,0 +1,94 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{
+		Key:            KeyByMessage,
+		Limit:          Limit{Rate: 0.001, Burst: 2},
+		Levels:         map[Level]Limit{ErrorLevel: {}},
+		ReportInterval: 50 * time.Millisecond,
+	})
+
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core), WithRateLimit(limiter))
+	for i := 0; i < 5; i++ {
+		logger.Info("a")
+		logger.Info("b")
+		logger.Error("c")
+	}
+	if limiter.Dropped() != 6 {
+		t.Error("dropped is", limiter.Dropped())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if logs.Len() != 10 {
+		t.Fatal(logs.AllUntimed())
+	}
+	for msg, count := range map[string]int{"a": 2, "b": 2, "c": 5} {
+		if n := logs.FilterMessage(msg).Len(); n != count {
+			t.Error(msg, n)
+		}
+	}
+	last := logs.All()[9]
+	if last.Level != WarnLevel ||
+		last.Message != "log entries dropped by rate limit" ||
+		last.ContextMap()["dropped"] != uint64(6) {
+		t.Error(last)
+	}
+}
+
+func TestRateLimiterDelegatesCheck(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{Limit: Limit{Rate: 1000, Burst: 1000}})
+	debugCore, debugLogs := observer.New(DebugLevel)
+	errorCore, errorLogs := observer.New(ErrorLevel)
+	core := zapcore.NewSampler(zapcore.NewTee(debugCore, errorCore), time.Hour, 1, 1000)
+	logger := NewLogger(zap.New(core), WithRateLimit(limiter))
+
+	for i := 0; i < 10; i++ {
+		logger.Debug("a")
+	}
+	logger.Error("b")
+
+	if n := debugLogs.FilterMessage("a").Len(); n != 1 {
+		t.Error("sampled debug entries are", n)
+	}
+	if errorLogs.Len() != 1 || errorLogs.All()[0].Message != "b" {
+		t.Error(errorLogs.AllUntimed())
+	}
+}
+
+func TestRateLimiterSharedLevels(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{
+		Limit:  Limit{Rate: 0.001, Burst: 2},
+		Levels: map[Level]Limit{WarnLevel: {Rate: 0.001, Burst: 1}},
+	})
+
+	core, logs := observer.New(DebugLevel)
+	logger := zap.New(limiter.WrapCore(core))
+	for i := 0; i < 3; i++ {
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warn("warn")
+		logger.Error("error")
+		logger.DPanic("dpanic")
+	}
+
+	// debug, info and error share a bucket of the logger, warn has its own
+	for msg, count := range map[string]int{"debug": 1, "info": 1, "warn": 1, "error": 0, "dpanic": 3} {
+		if n := logs.FilterMessage(msg).Len(); n != count {
+			t.Error(msg, n)
+		}
+	}
+	if limiter.Dropped() != 9 {
+		t.Error("dropped is", limiter.Dropped())
+	}
+}
@@ -0,0 +1,146 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/runner-mei/log/stacktrace"
+)
+
+// Recoverer logs the recovered panics.
+type Recoverer struct {
+	// Level is the level of the entries, such as ErrorLevel or PanicLevel.
+	Level Level
+	// Message is the message of the entries.
+	Message string
+	// RePanic re-panics with the recovered value after logging it, otherwise
+	// the execution continues after the deferred Recover.
+	RePanic bool
+}
+
+// DefaultRecoverer is the Recoverer used by Recover and RecoverHandler.
+var DefaultRecoverer = Recoverer{
+	Level:   ErrorLevel,
+	Message: "panic recovered",
+}
+
+// Recover recovers a panic and logs it with DefaultRecoverer, it must be
+// deferred directly, such as
+//
+//	defer log.Recover(logger, log.String("job", name))
+func Recover(logger Logger, fields ...Field) {
+	if value := recover(); value != nil {
+		DefaultRecoverer.log(logger, value, fields)
+		if DefaultRecoverer.RePanic {
+			panic(value)
+		}
+	}
+}
+
+// RecoverHandler recovers the panics of next with DefaultRecoverer, see
+// Recoverer.Handler.
+func RecoverHandler(logger Logger, next http.Handler) http.Handler {
+	return DefaultRecoverer.Handler(logger, next)
+}
+
+// Recover recovers a panic and logs it with the panic value, its type and
+// the stack, it must be deferred directly, such as
+//
+//	defer recoverer.Recover(logger)
+func (r Recoverer) Recover(logger Logger, fields ...Field) {
+	if value := recover(); value != nil {
+		r.log(logger, value, fields)
+		if r.RePanic {
+			panic(value)
+		}
+	}
+}
+
+// Handler recovers the panics of next, logs them with the fields of the
+// request, and writes a 500 response if the headers haven't been sent. The
+// Logger is logger, or the one of For(ctx) if logger is nil.
+//
+// http.ErrAbortHandler is re-panicked without logging.
+func (r Recoverer) Handler(logger Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+
+			requestLogger := logger
+			if requestLogger == nil {
+				requestLogger = For(req.Context())
+			} else {
+				requestLogger = For(req.Context(), requestLogger)
+			}
+			r.log(requestLogger, value, []Field{
+				String("method", req.Method),
+				String("path", req.URL.Path),
+				String("remote_addr", req.RemoteAddr),
+			})
+
+			if sw.status == 0 {
+				http.Error(sw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			if r.RePanic {
+				panic(value)
+			}
+		}()
+		next.ServeHTTP(sw, req)
+	})
+}
+
+// LogPanic logs the recovered value with the panic value, its type and the
+// stack, for the callers which recover the panic themselves.
+func (r Recoverer) LogPanic(logger Logger, value interface{}, fields ...Field) {
+	r.log(logger.AddCallerSkip(1), value, fields)
+}
+
+func (r Recoverer) log(logger Logger, value interface{}, fields []Field) {
+	message := r.Message
+	if message == "" {
+		message = DefaultRecoverer.Message
+	}
+
+	fields = append(fields,
+		String("panic", fmt.Sprint(value)),
+		String("panic_type", fmt.Sprintf("%T", value)),
+		String("stack", panicStack()))
+	if err, ok := value.(error); ok {
+		fields = append(fields, Error(err))
+	}
+
+	logger = logger.AddCallerSkip(1)
+	if r.Level == PanicLevel {
+		// the panic of logger.Panic is discarded, RePanic decides whether the
+		// recovered value is re-panicked
+		defer func() { recover() }()
+	}
+	LogAt(logger, r.Level, message, fields...)
+}
+
+// panicStack returns the stack of the panicking goroutine, which starts from
+// the function that panics.
+func panicStack() string {
+	stack := stacktrace.Capture(1, stacktrace.Full)
+	defer stack.Free()
+
+	var frames []string
+	for frame, more := stack.Next(); more; frame, more = stack.Next() {
+		if frame.Function == "runtime.gopanic" {
+			// drops the frames of the recovery
+			frames = frames[:0]
+			continue
+		}
+		frames = append(frames, frame.Function+"\n\t"+frame.File+":"+strconv.Itoa(frame.Line))
+	}
+	return strings.Join(frames, "\n")
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func panicking() {
+	panic(errors.New("boom"))
+}
+
+func TestRecover(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	func() {
+		defer Recover(logger, String("job", "a"))
+		panicking()
+	}()
+
+	entries := logs.TakeAll()
+	if len(entries) != 1 {
+		t.Fatal(entries)
+	}
+	fields := entries[0].ContextMap()
+	if entries[0].Level != ErrorLevel || fields["job"] != "a" || fields["panic"] != "boom" ||
+		fields["panic_type"] != "*errors.errorString" || fields["error"] != "boom" {
+		t.Error(fields)
+	}
+	if stack := fields["stack"].(string); !strings.HasPrefix(stack, "github.com/runner-mei/log.panicking") {
+		t.Error(stack)
+	}
+
+	recoverer := Recoverer{Level: PanicLevel, RePanic: true}
+	defer func() {
+		if value := recover(); value == nil || value.(error).Error() != "boom" {
+			t.Error(value)
+		}
+		if entries := logs.TakeAll(); len(entries) != 1 || entries[0].Level != PanicLevel ||
+			entries[0].Message != "panic recovered" {
+			t.Error(entries)
+		}
+	}()
+	func() {
+		defer recoverer.Recover(logger)
+		panicking()
+	}()
+}
+
+func TestRecoverHandler(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	handler := NewHTTPMiddleware(logger, HTTPConfig{})(RecoverHandler(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("bad request")
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Error(rec.Code)
+	}
+
+	entries := logs.TakeAll()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["panic"] != "bad request" || fields["path"] != "/panic" || fields["request_id"] == nil {
+		t.Error(fields)
+	}
+	if fields := entries[1].ContextMap(); entries[1].Level != ErrorLevel || fields["status"] != int64(500) {
+		t.Error(fields)
+	}
+}
+
+func TestRecoverHandlerSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	core, logs := observer.New(DebugLevel)
+	handler := NewHTTPMiddleware(NewLogger(zap.New(core)), HTTPConfig{})(RecoverHandler(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("bad request")
+	})))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	if fields := entries[0].ContextMap(); len(entries[0].Context) != len(fields) || fields["span_id"] == nil {
+		t.Error("duplicated fields", entries[0].Context)
+	}
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatal(spans)
+	}
+	if records := spans[0].Logs(); len(records) != 2 {
+		t.Error(records)
+	}
+}
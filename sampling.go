@@ -0,0 +1,84 @@
+package log
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithSampling samples the entries of the Logger: in every tick, the first
+// entries of each level and message are logged, and thereafter only every
+// thereafter-th entry is logged.
+func WithSampling(tick time.Duration, first, thereafter int) Option {
+	return WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, tick, first, thereafter)
+	})
+}
+
+// callSite is the state of a call site of Every, EveryN or Once.
+type callSite struct {
+	count uint64
+	last  int64
+}
+
+var callSites sync.Map
+
+// callSiteOf returns the state of the call site which is skip frames above
+// the caller of callSiteOf.
+func callSiteOf(skip int) *callSite {
+	var pcs [1]uintptr
+	runtime.Callers(skip+2, pcs[:])
+	if site, ok := callSites.Load(pcs[0]); ok {
+		return site.(*callSite)
+	}
+	site, _ := callSites.LoadOrStore(pcs[0], &callSite{})
+	return site.(*callSite)
+}
+
+func (site *callSite) every(interval time.Duration) bool {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&site.last)
+	if last != 0 && now-last < int64(interval) {
+		return false
+	}
+	return atomic.CompareAndSwapInt64(&site.last, last, now)
+}
+
+func (site *callSite) everyN(n int) bool {
+	count := atomic.AddUint64(&site.count, 1)
+	return n <= 1 || (count-1)%uint64(n) == 0
+}
+
+func (site *callSite) once() bool {
+	return atomic.CompareAndSwapUint64(&site.count, 0, 1)
+}
+
+// every returns logger if the call site of the Logger.Every method has not
+// logged in the interval, or Empty otherwise.
+func every(logger Logger, interval time.Duration) Logger {
+	if callSiteOf(2).every(interval) {
+		return logger
+	}
+	return empty
+}
+
+// everyN returns logger for every n-th call of the call site of the
+// Logger.EveryN method, or Empty otherwise.
+func everyN(logger Logger, n int) Logger {
+	if callSiteOf(2).everyN(n) {
+		return logger
+	}
+	return empty
+}
+
+// once returns logger for the first call of the call site of the Logger.Once
+// method, or Empty otherwise.
+func once(logger Logger) Logger {
+	if callSiteOf(2).once() {
+		return logger
+	}
+	return empty
+}
@@ -0,0 +1,33 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCallSiteSampling(t *testing.T) {
+	callSites.Range(func(key, value interface{}) bool {
+		callSites.Delete(key)
+		return true
+	})
+
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	for i := 0; i < 10; i++ {
+		logger.Once().Info("once")
+		logger.EveryN(4).Info("every4")
+		logger.Every(time.Hour).Info("every")
+		logger.Info("always")
+	}
+	logger.Once().Info("once")
+
+	for msg, count := range map[string]int{"once": 2, "every4": 3, "every": 1, "always": 10} {
+		if n := logs.FilterMessage(msg).Len(); n != count {
+			t.Error(msg, n)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// TraceIDKey is the field name of the trace id added by the span-aware
+	// loggers, an empty name disables the field.
+	TraceIDKey = "trace_id"
+
+	// SpanIDKey is the field name of the span id added by the span-aware
+	// loggers, an empty name disables the field.
+	SpanIDKey = "span_id"
+)
+
+// SpanIDExtractor returns the trace id and the span id of an opentracing
+// span context, ok is false if it doesn't know the span context.
+type SpanIDExtractor func(sc opentracing.SpanContext) (traceID, spanID string, ok bool)
+
+var (
+	spanIDExtractorsLock sync.RWMutex
+	spanIDExtractors     []SpanIDExtractor
+)
+
+// RegisterSpanIDExtractor adds an extractor for the span contexts of a
+// tracer. The extractors are tried in the reverse order of registration, and
+// the span contexts which have TraceID and SpanID (or ID) methods or fields,
+// such as the ones of Jaeger, Zipkin and mocktracer, are known by default.
+func RegisterSpanIDExtractor(extractor SpanIDExtractor) {
+	spanIDExtractorsLock.Lock()
+	spanIDExtractors = append(spanIDExtractors, extractor)
+	spanIDExtractorsLock.Unlock()
+}
+
+// SpanIDs returns the trace id and the span id of span, which is an
+// opentracing.Span, an opentracing.SpanContext, an OpenTelemetry trace.Span
+// or an OpenTelemetry trace.SpanContext.
+func SpanIDs(span interface{}) (traceID, spanID string, ok bool) {
+	switch s := span.(type) {
+	case opentracing.Span:
+		return opentracingSpanIDs(s.Context())
+	case opentracing.SpanContext:
+		return opentracingSpanIDs(s)
+	case trace.Span:
+		return otelSpanIDs(s.SpanContext())
+	case trace.SpanContext:
+		return otelSpanIDs(s)
+	}
+	return "", "", false
+}
+
+func otelSpanIDs(sc trace.SpanContext) (string, string, bool) {
+	if !sc.IsValid() {
+		return "", "", false
+	}
+	return sc.TraceID().String(), sc.SpanID().String(), true
+}
+
+func opentracingSpanIDs(sc opentracing.SpanContext) (string, string, bool) {
+	if sc == nil {
+		return "", "", false
+	}
+
+	spanIDExtractorsLock.RLock()
+	extractors := spanIDExtractors
+	spanIDExtractorsLock.RUnlock()
+
+	for idx := len(extractors) - 1; idx >= 0; idx-- {
+		if traceID, spanID, ok := extractors[idx](sc); ok {
+			return traceID, spanID, true
+		}
+	}
+	return reflectSpanIDs(sc)
+}
+
+// reflectSpanIDs reads the TraceID and SpanID (or ID) methods or fields of
+// the span context.
+func reflectSpanIDs(sc opentracing.SpanContext) (string, string, bool) {
+	value := reflect.ValueOf(sc)
+	traceID, ok := reflectID(value, "TraceID")
+	if !ok {
+		return "", "", false
+	}
+	spanID, ok := reflectID(value, "SpanID", "ID")
+	if !ok {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+func reflectID(value reflect.Value, names ...string) (string, bool) {
+	for _, name := range names {
+		if method := value.MethodByName(name); method.IsValid() &&
+			method.Type().NumIn() == 0 && method.Type().NumOut() == 1 {
+			return idString(method.Call(nil)[0]), true
+		}
+
+		if v := reflect.Indirect(value); v.Kind() == reflect.Struct {
+			if field := v.FieldByName(name); field.IsValid() && field.CanInterface() {
+				return idString(field), true
+			}
+		}
+	}
+	return "", false
+}
+
+func idString(value reflect.Value) string {
+	if s, ok := value.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// spanIDFields returns the trace id and span id fields of the span.
+func spanIDFields(span interface{}) []Field {
+	if TraceIDKey == "" && SpanIDKey == "" {
+		return nil
+	}
+	traceID, spanID, ok := SpanIDs(span)
+	if !ok {
+		return nil
+	}
+
+	fields := make([]Field, 0, 2)
+	if TraceIDKey != "" {
+		fields = append(fields, String(TraceIDKey, traceID))
+	}
+	if SpanIDKey != "" {
+		fields = append(fields, String(SpanIDKey, spanID))
+	}
+	return fields
+}
@@ -0,0 +1,41 @@
+package log
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSpanIDFields(t *testing.T) {
+	core, logs := observer.New(DebugLevel)
+	logger := NewLogger(zap.New(core))
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	Span(logger, span).Info("a")
+	span.Finish()
+
+	sc := span.Context().(mocktracer.MockSpanContext)
+	fields := logs.All()[0].ContextMap()
+	if fields["trace_id"] != strconv.Itoa(sc.TraceID) || fields["span_id"] != strconv.Itoa(sc.SpanID) {
+		t.Error(fields)
+	}
+	if records := tracer.FinishedSpans()[0].Logs(); len(records) != 1 || len(records[0].Fields) != 2 {
+		t.Error(records)
+	}
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1, 2, 3},
+		SpanID:  trace.SpanID{4, 5, 6},
+	}))
+	For(ctx, logger).Info("b")
+	fields = logs.All()[1].ContextMap()
+	if fields["trace_id"] != "01020300000000000000000000000000" || fields["span_id"] != "0405060000000000" {
+		t.Error(fields)
+	}
+}
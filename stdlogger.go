@@ -1,8 +1,10 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	stdlog "log"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/exp/slog"
@@ -165,6 +167,34 @@ func (l stdlogger) Named(name string) Logger {
 	return stdlogger{name: newName, logger: l.logger, callerSkip: l.callerSkip}
 }
 
+func (l stdlogger) Every(interval time.Duration) Logger {
+	return every(l, interval)
+}
+
+func (l stdlogger) EveryN(n int) Logger {
+	return everyN(l, n)
+}
+
+func (l stdlogger) Once() Logger {
+	return once(l)
+}
+
+func (l stdlogger) DebugContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Debug(msg, fields...)
+}
+
+func (l stdlogger) InfoContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Info(msg, fields...)
+}
+
+func (l stdlogger) WarnContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Warn(msg, fields...)
+}
+
+func (l stdlogger) ErrorContext(ctx context.Context, msg string, fields ...Field) {
+	withContext(ctx, l).Error(msg, fields...)
+}
+
 func (l stdlogger) AddCallerSkip(level int) Logger {
 	return stdlogger{name: l.name, fields: l.fields, logger: l.logger, callerSkip: l.callerSkip + level}
 }
@@ -1,10 +1,18 @@
 package log
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/opentracing/opentracing-go/log"
+	"github.com/runner-mei/log/stacktrace"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -45,126 +53,328 @@ func (callback Callback) LogFields(level Level, msg string, fields ...Field) {
 	callback(level, msg, fields...)
 }
 
+// OutputToStrings appends the messages with the level prefixes of
+// DefaultLocale to target.
+//
+// Deprecated: use Messages, which keeps the levels and renders the messages
+// in the language of the user.
 func OutputToStrings(enabledLevel Level, target *[]string) Callback {
 	return Callback(func(level Level, msg string, fields ...Field) {
 		if !enabledLevel.Enabled(level) {
 			return
 		}
-
-		switch level {
-		case InfoLevel:
-			msg = "信息：" + msg
-		case WarnLevel:
-			msg = "警告：" + msg
-		case ErrorLevel:
-			msg = "错误：" + msg
-		case DPanicLevel, PanicLevel:
-			msg = "异常：" + msg
-		case FatalLevel:
-			msg = "致命错误：" + msg
-		}
-		*target = append(*target, msg)
+		*target = append(*target, DefaultLocale.Render(level, msg))
 	})
 }
 
+// SpanErrorLevel is the minimum level of the entries which mark the span as
+// failed, such as setting the error tag of an opentracing span.
+var SpanErrorLevel = ErrorLevel
+
 func OutputToTracer(enabledLevel Level, span opentracing.Span) Callback {
 	return Callback(func(level Level, msg string, fields ...Field) {
-		if !enabledLevel.Enabled(level) {
+		failed := SpanErrorLevel.Enabled(level)
+		if failed {
+			ext.Error.Set(span, true)
+		}
+		if !enabledLevel.Enabled(level) || !isSampled(span) {
 			return
 		}
-		// TODO rather than always converting the fields, we could wrap them into a lazy logger
-		fa := fieldAdapter(make([]log.Field, 0, 2+len(fields)))
-		fa = append(fa, log.String("event", msg))
-		fa = append(fa, log.String("level", level.String()))
+
+		// the fields are converted only if the span is recorded, they aren't
+		// wrapped into a lazy logger because they may be changed after
+		// returning, such as a reflected value.
+		fa := &fieldAdapter{fields: make([]log.Field, 0, 2+len(fields))}
+		fa.add(log.String("event", msg))
+		fa.add(log.String("level", level.String()))
 		for _, field := range fields {
-			field.AddTo(&fa)
+			field.AddTo(fa)
+		}
+
+		if err := errorOf(fields); failed && err != nil {
+			// see https://github.com/opentracing/specification/blob/master/semantic_conventions.md#log-fields-table
+			fa.fields[0] = log.String("event", "error")
+			fa.add(log.String("message", msg))
+			fa.add(log.String("error.kind", fmt.Sprintf("%T", err)))
+			fa.add(log.Object("error.object", err))
+			fa.add(log.String("stack", takeStack()))
 		}
-		span.LogFields(fa...)
+		span.LogFields(fa.fields...)
 	})
 }
 
-type fieldAdapter []log.Field
+// SpanSampler reports whether an opentracing span context is sampled, ok is
+// false if it doesn't know the span context.
+type SpanSampler func(sc opentracing.SpanContext) (sampled, ok bool)
+
+var (
+	spanSamplersLock sync.RWMutex
+	spanSamplers     []SpanSampler
+)
+
+// RegisterSpanSampler adds a SpanSampler for the span contexts of a tracer.
+// The samplers are tried in the reverse order of registration, and the span
+// contexts which have an IsSampled method, such as the ones of Jaeger, are
+// known by default.
+func RegisterSpanSampler(sampler SpanSampler) {
+	spanSamplersLock.Lock()
+	spanSamplers = append(spanSamplers, sampler)
+	spanSamplersLock.Unlock()
+}
+
+// spanContextSampled reports whether the span context is sampled, known is
+// false if none of the samplers knows it.
+func spanContextSampled(sc opentracing.SpanContext) (sampled, known bool) {
+	spanSamplersLock.RLock()
+	for idx := len(spanSamplers) - 1; idx >= 0; idx-- {
+		if sampled, ok := spanSamplers[idx](sc); ok {
+			spanSamplersLock.RUnlock()
+			return sampled, true
+		}
+	}
+	spanSamplersLock.RUnlock()
+
+	if s, ok := sc.(interface{ IsSampled() bool }); ok {
+		return s.IsSampled(), true
+	}
+	return false, false
+}
+
+// isSampled reports whether the span may be recorded by its tracer.
+func isSampled(span opentracing.Span) bool {
+	if _, ok := span.Tracer().(opentracing.NoopTracer); ok {
+		return false
+	}
+	if sampled, known := spanContextSampled(span.Context()); known {
+		return sampled
+	}
+	return true
+}
+
+// errorOf returns the error of the first Error field.
+func errorOf(fields []Field) error {
+	for _, field := range fields {
+		if field.Type == zapcore.ErrorType {
+			if err, ok := field.Interface.(error); ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// takeStack returns the stacktrace of the caller outside of this package.
+func takeStack() string {
+	stack := stacktrace.Capture(1, stacktrace.Full)
+	defer stack.Free()
+
+	var sb strings.Builder
+	skipping := true
+	for frame, more := stack.Next(); more; frame, more = stack.Next() {
+		if skipping && strings.HasPrefix(frame.Function, packagePrefix) {
+			continue
+		}
+		skipping = false
+
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+	}
+	return sb.String()
+}
+
+// packagePrefix is the prefix of the functions in this package.
+const packagePrefix = "github.com/runner-mei/log."
+
+var (
+	// SpanTimeEncoder encodes the time fields echoed into the spans.
+	SpanTimeEncoder zapcore.TimeEncoder = zapcore.RFC3339NanoTimeEncoder
+
+	// SpanDurationEncoder encodes the duration fields echoed into the spans.
+	SpanDurationEncoder zapcore.DurationEncoder = zapcore.StringDurationEncoder
+)
+
+// fieldAdapter converts the zap fields into opentracing log fields, the
+// nested objects and namespaces are flattened with dotted keys, the arrays
+// are JSON-encoded.
+type fieldAdapter struct {
+	fields []log.Field
+	prefix string
+}
+
+func (fa *fieldAdapter) add(field log.Field) {
+	fa.fields = append(fa.fields, field)
+}
+
+func (fa *fieldAdapter) key(key string) string {
+	return fa.prefix + key
+}
 
 func (fa *fieldAdapter) AddBool(key string, value bool) {
-	*fa = append(*fa, log.Bool(key, value))
+	fa.add(log.Bool(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddFloat64(key string, value float64) {
-	*fa = append(*fa, log.Float64(key, value))
+	fa.add(log.Float64(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddFloat32(key string, value float32) {
-	*fa = append(*fa, log.Float64(key, float64(value)))
+	fa.add(log.Float32(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddInt(key string, value int) {
-	*fa = append(*fa, log.Int(key, value))
+	fa.add(log.Int(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddInt64(key string, value int64) {
-	*fa = append(*fa, log.Int64(key, value))
+	fa.add(log.Int64(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddInt32(key string, value int32) {
-	*fa = append(*fa, log.Int64(key, int64(value)))
+	fa.add(log.Int32(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddInt16(key string, value int16) {
-	*fa = append(*fa, log.Int64(key, int64(value)))
+	fa.add(log.Int32(fa.key(key), int32(value)))
 }
 
 func (fa *fieldAdapter) AddInt8(key string, value int8) {
-	*fa = append(*fa, log.Int64(key, int64(value)))
+	fa.add(log.Int32(fa.key(key), int32(value)))
 }
 
 func (fa *fieldAdapter) AddUint(key string, value uint) {
-	*fa = append(*fa, log.Uint64(key, uint64(value)))
+	fa.add(log.Uint64(fa.key(key), uint64(value)))
 }
 
 func (fa *fieldAdapter) AddUint64(key string, value uint64) {
-	*fa = append(*fa, log.Uint64(key, value))
+	fa.add(log.Uint64(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddUint32(key string, value uint32) {
-	*fa = append(*fa, log.Uint64(key, uint64(value)))
+	fa.add(log.Uint32(fa.key(key), value))
 }
 
 func (fa *fieldAdapter) AddUint16(key string, value uint16) {
-	*fa = append(*fa, log.Uint64(key, uint64(value)))
+	fa.add(log.Uint32(fa.key(key), uint32(value)))
 }
 
 func (fa *fieldAdapter) AddUint8(key string, value uint8) {
-	*fa = append(*fa, log.Uint64(key, uint64(value)))
+	fa.add(log.Uint32(fa.key(key), uint32(value)))
 }
 
-func (fa *fieldAdapter) AddUintptr(key string, value uintptr)                        {}
-func (fa *fieldAdapter) AddArray(key string, marshaler zapcore.ArrayMarshaler) error { return nil }
-func (fa *fieldAdapter) AddComplex128(key string, value complex128)                  {}
-func (fa *fieldAdapter) AddComplex64(key string, value complex64)                    {}
-func (fa *fieldAdapter) AddObject(key string, value zapcore.ObjectMarshaler) error   { return nil }
-func (fa *fieldAdapter) AddReflected(key string, value interface{}) error            { return nil }
-func (fa *fieldAdapter) OpenNamespace(key string)                                    {}
+func (fa *fieldAdapter) AddUintptr(key string, value uintptr) {
+	fa.add(log.Uint64(fa.key(key), uint64(value)))
+}
+
+func (fa *fieldAdapter) AddComplex128(key string, value complex128) {
+	fa.add(log.String(fa.key(key), strconv.FormatComplex(value, 'g', -1, 128)))
+}
+
+func (fa *fieldAdapter) AddComplex64(key string, value complex64) {
+	fa.add(log.String(fa.key(key), strconv.FormatComplex(complex128(value), 'g', -1, 64)))
+}
+
+func (fa *fieldAdapter) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	enc := zapcore.NewMapObjectEncoder()
+	err := enc.AddArray(key, marshaler)
+	if bs, e := json.Marshal(enc.Fields[key]); e == nil {
+		fa.add(log.String(fa.key(key), string(bs)))
+	} else if err == nil {
+		err = e
+	}
+	return err
+}
+
+func (fa *fieldAdapter) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	nested := &fieldAdapter{fields: fa.fields, prefix: fa.key(key) + "."}
+	err := marshaler.MarshalLogObject(nested)
+	fa.fields = nested.fields
+	return err
+}
+
+func (fa *fieldAdapter) AddReflected(key string, value interface{}) error {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		fa.add(log.String(fa.key(key), fmt.Sprint(value)))
+		return err
+	}
+	fa.add(log.String(fa.key(key), string(bs)))
+	return nil
+}
+
+func (fa *fieldAdapter) OpenNamespace(key string) {
+	fa.prefix = fa.key(key) + "."
+}
 
 func (fa *fieldAdapter) AddDuration(key string, value time.Duration) {
-	// TODO inefficient
-	*fa = append(*fa, log.String(key, value.String()))
+	pa := primitiveAdapter{key: fa.key(key)}
+	SpanDurationEncoder(value, &pa)
+	if !pa.set {
+		pa.field = log.Int64(pa.key, int64(value))
+	}
+	fa.add(pa.field)
 }
 
 func (fa *fieldAdapter) AddTime(key string, value time.Time) {
-	// TODO inefficient
-	*fa = append(*fa, log.String(key, value.String()))
+	pa := primitiveAdapter{key: fa.key(key)}
+	SpanTimeEncoder(value, &pa)
+	if !pa.set {
+		pa.field = log.Int64(pa.key, value.UnixNano())
+	}
+	fa.add(pa.field)
 }
 
 func (fa *fieldAdapter) AddBinary(key string, value []byte) {
-	*fa = append(*fa, log.Object(key, value))
+	fa.add(log.String(fa.key(key), base64.StdEncoding.EncodeToString(value)))
 }
 
 func (fa *fieldAdapter) AddByteString(key string, value []byte) {
-	*fa = append(*fa, log.Object(key, value))
+	fa.add(log.String(fa.key(key), string(value)))
 }
 
 func (fa *fieldAdapter) AddString(key, value string) {
-	if key != "" && value != "" {
-		*fa = append(*fa, log.String(key, value))
+	fa.add(log.String(fa.key(key), value))
+}
+
+// primitiveAdapter converts the value appended by a zapcore.TimeEncoder or a
+// zapcore.DurationEncoder into an opentracing log field.
+type primitiveAdapter struct {
+	key   string
+	field log.Field
+	set   bool
+}
+
+func (pa *primitiveAdapter) append(field log.Field) {
+	if !pa.set {
+		pa.field = field
+		pa.set = true
 	}
 }
+
+func (pa *primitiveAdapter) AppendBool(v bool)         { pa.append(log.Bool(pa.key, v)) }
+func (pa *primitiveAdapter) AppendByteString(v []byte) { pa.append(log.String(pa.key, string(v))) }
+func (pa *primitiveAdapter) AppendComplex128(v complex128) {
+	pa.append(log.String(pa.key, strconv.FormatComplex(v, 'g', -1, 128)))
+}
+func (pa *primitiveAdapter) AppendComplex64(v complex64) {
+	pa.append(log.String(pa.key, strconv.FormatComplex(complex128(v), 'g', -1, 64)))
+}
+func (pa *primitiveAdapter) AppendFloat64(v float64) { pa.append(log.Float64(pa.key, v)) }
+func (pa *primitiveAdapter) AppendFloat32(v float32) { pa.append(log.Float32(pa.key, v)) }
+func (pa *primitiveAdapter) AppendInt(v int)         { pa.append(log.Int(pa.key, v)) }
+func (pa *primitiveAdapter) AppendInt64(v int64)     { pa.append(log.Int64(pa.key, v)) }
+func (pa *primitiveAdapter) AppendInt32(v int32)     { pa.append(log.Int32(pa.key, v)) }
+func (pa *primitiveAdapter) AppendInt16(v int16)     { pa.append(log.Int32(pa.key, int32(v))) }
+func (pa *primitiveAdapter) AppendInt8(v int8)       { pa.append(log.Int32(pa.key, int32(v))) }
+func (pa *primitiveAdapter) AppendString(v string)   { pa.append(log.String(pa.key, v)) }
+func (pa *primitiveAdapter) AppendUint(v uint)       { pa.append(log.Uint64(pa.key, uint64(v))) }
+func (pa *primitiveAdapter) AppendUint64(v uint64)   { pa.append(log.Uint64(pa.key, v)) }
+func (pa *primitiveAdapter) AppendUint32(v uint32)   { pa.append(log.Uint32(pa.key, v)) }
+func (pa *primitiveAdapter) AppendUint16(v uint16)   { pa.append(log.Uint32(pa.key, uint32(v))) }
+func (pa *primitiveAdapter) AppendUint8(v uint8)     { pa.append(log.Uint32(pa.key, uint32(v))) }
+func (pa *primitiveAdapter) AppendUintptr(v uintptr) { pa.append(log.Uint64(pa.key, uint64(v))) }
@@ -0,0 +1,185 @@
+package log
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	RegisterSpanSampler(func(sc opentracing.SpanContext) (bool, bool) {
+		mock, ok := sc.(mocktracer.MockSpanContext)
+		return mock.Sampled, ok
+	})
+}
+
+func TestOutputToTracerError(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	logger := Span(Empty(), span, InfoLevel)
+	logger.Warn("a", Error(errors.New("abc")))
+	logger.Error("b", Error(errors.New("abc")))
+	span.Finish()
+
+	finished := tracer.FinishedSpans()[0]
+	if finished.Tag("error") != true {
+		t.Error(finished.Tags())
+	}
+
+	records := finished.Logs()
+	if len(records) != 2 {
+		t.Fatal(records)
+	}
+	if records[0].Fields[0].ValueString != "a" {
+		t.Error(records[0].Fields)
+	}
+
+	fields := map[string]string{}
+	for _, field := range records[1].Fields {
+		fields[field.Key] = field.ValueString
+	}
+	if fields["event"] != "error" ||
+		fields["message"] != "b" ||
+		fields["error"] != "abc" ||
+		fields["error.kind"] != "*errors.errorString" ||
+		fields["error.object"] != "abc" ||
+		!strings.Contains(fields["stack"], "testing.tRunner") {
+		t.Error(fields)
+	}
+}
+
+type testObject struct {
+	Name string
+	Tags []string
+}
+
+func (o testObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", o.Name)
+	enc.OpenNamespace("ns")
+	return enc.AddArray("tags", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, tag := range o.Tags {
+			arr.AppendString(tag)
+		}
+		return nil
+	}))
+}
+
+func TestOutputToTracerUnsampled(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	ext.SamplingPriority.Set(span, 0)
+	Span(Empty(), span).Info("a")
+	span.Finish()
+
+	if logs := tracer.FinishedSpans()[0].Logs(); len(logs) != 0 {
+		t.Error(logs)
+	}
+}
+
+func TestOutputToTracerFields(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	Span(Empty(), span).Info("a",
+		String("empty", ""),
+		Object("obj", testObject{Name: "x", Tags: []string{"a", "b"}}),
+		Any("reflected", map[string]int{"k": 1}),
+		zap.Complex128("c", 1+2i),
+		zap.Uintptr("p", 10),
+		Duration("d", time.Second),
+		Time("t", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+		zap.Namespace("last"),
+		Int("i", 1))
+	span.Finish()
+
+	fields := map[string]string{}
+	for _, field := range tracer.FinishedSpans()[0].Logs()[0].Fields {
+		fields[field.Key] = field.ValueString
+	}
+	excepted := map[string]string{
+		"event":       "a",
+		"level":       "info",
+		"empty":       "",
+		"obj.name":    "x",
+		"obj.ns.tags": `["a","b"]`,
+		"reflected":   `{"k":1}`,
+		"c":           "(1+2i)",
+		"p":           "10",
+		"d":           "1s",
+		"t":           "2020-01-02T03:04:05Z",
+		"last.i":      "1",
+	}
+	if !reflect.DeepEqual(fields, excepted) {
+		t.Error(fields)
+	}
+}
+
+func TestOTelAttributes(t *testing.T) {
+	attrs := otelAttributes([]Field{
+		Object("obj", testObject{Name: "x", Tags: []string{"a", "b"}}),
+		Any("reflected", map[string]int{"k": 1}),
+		zap.Complex128("c", 1+2i),
+		zap.Uintptr("p", 10),
+		Duration("d", time.Second),
+		zap.Namespace("last"),
+		Int("i", 1),
+	}, attribute.String("level", "info"))
+
+	values := map[string]string{}
+	for _, attr := range attrs {
+		values[string(attr.Key)] = attr.Value.Emit()
+	}
+	excepted := map[string]string{
+		"level":       "info",
+		"obj.name":    "x",
+		"obj.ns.tags": `["a","b"]`,
+		"reflected":   `{"k":1}`,
+		"c":           "(1+2i)",
+		"p":           "10",
+		"d":           "1s",
+		"last.i":      "1",
+	}
+	if !reflect.DeepEqual(values, excepted) {
+		t.Error(values)
+	}
+}
+
+func benchmarkOutputToTracer(b *testing.B, span opentracing.Span) {
+	target := OutputToTracer(DebugLevel, span)
+	fields := []Field{
+		String("s", "abc"),
+		Int("i", 1),
+		Duration("d", time.Second),
+		Object("obj", testObject{Name: "x", Tags: []string{"a", "b"}}),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		target.LogFields(InfoLevel, "abc", fields...)
+	}
+}
+
+func BenchmarkOutputToTracerSampled(b *testing.B) {
+	span := mocktracer.New().StartSpan("test")
+	benchmarkOutputToTracer(b, span)
+}
+
+func BenchmarkOutputToTracerUnsampled(b *testing.B) {
+	span := mocktracer.New().StartSpan("test")
+	ext.SamplingPriority.Set(span, 0)
+	benchmarkOutputToTracer(b, span)
+}
+
+func BenchmarkOutputToTracerNoop(b *testing.B) {
+	span := opentracing.NoopTracer{}.StartSpan("test")
+	benchmarkOutputToTracer(b, span)
+}
@@ -0,0 +1,125 @@
+package log
+
+import (
+	"context"
+	"strconv"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	// TraceDebug enables TraceDebugLevel on the span-aware loggers of the
+	// sampled traces and of the traces which carry the debug flag, so that a
+	// global InfoLevel can coexist with the debug entries of the sampled
+	// fraction of the requests.
+	TraceDebug = false
+
+	// TraceDebugLevel is the minimum level of the span-aware loggers of the
+	// sampled or debug traces when TraceDebug is enabled, it lowers the level
+	// of the underlying logger.
+	TraceDebugLevel = DebugLevel
+
+	// DebugBaggageKey is the baggage key of the debug flag, whose value is
+	// parsed by strconv.ParseBool. An empty key disables the baggage flag.
+	DebugBaggageKey = "debug"
+)
+
+// isTraceDebug reports whether the span is sampled or carries the debug flag
+// in its span context or baggage.
+func isTraceDebug(span interface{}) bool {
+	switch s := span.(type) {
+	case opentracing.Span:
+		if _, ok := s.Tracer().(opentracing.NoopTracer); ok {
+			return false
+		}
+		if sc, ok := s.Context().(interface{ IsDebug() bool }); ok && sc.IsDebug() {
+			// such as jaeger
+			return true
+		}
+		if sampled, _ := spanContextSampled(s.Context()); sampled {
+			return true
+		}
+		return DebugBaggageKey != "" && isDebugFlag(s.BaggageItem(DebugBaggageKey))
+	case trace.Span:
+		return s.SpanContext().IsSampled()
+	case TraceContext:
+		return s.Sampled() || (DebugBaggageKey != "" && isDebugFlag(s.Baggage[DebugBaggageKey]))
+	}
+	return false
+}
+
+// isOTelBaggageDebug reports whether the OpenTelemetry baggage in the ctx
+// carries the debug flag.
+func isOTelBaggageDebug(ctx context.Context) bool {
+	return DebugBaggageKey != "" && isDebugFlag(baggage.FromContext(ctx).Member(DebugBaggageKey).Value())
+}
+
+func isDebugFlag(value string) bool {
+	debug, _ := strconv.ParseBool(value)
+	return debug
+}
+
+// withTraceDebug lowers the minimum level of logger to TraceDebugLevel if
+// TraceDebug is enabled and the span is sampled or carries the debug flag.
+func withTraceDebug(logger Logger, span interface{}) Logger {
+	if !TraceDebug || !isTraceDebug(span) {
+		return logger
+	}
+	return withMinLevel(logger, TraceDebugLevel)
+}
+
+// withMinLevel returns a Logger which also logs the entries enabled by
+// level, even if the underlying core is disabled for them.
+func withMinLevel(logger Logger, level Level) Logger {
+	switch l := logger.(type) {
+	case zaplogger:
+		newL := l.logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return levelCore{Core: core, level: level}
+		}))
+		return zaplogger{logger: newL, sugared: newL.Sugar()}
+	case appendLogger:
+		return appendLogger{logger: withMinLevel(l.logger, level), target: l.target, bound: l.bound}
+	}
+	return logger
+}
+
+// levelCore enables the entries of level in addition to the ones which are
+// enabled by the core. They are checked by the core as the lowest level which
+// it enables, so that they are written only by the cores of that level, such
+// as the info sink but not the error sink of a Tee, and through their
+// samplers.
+type levelCore struct {
+	zapcore.Core
+	level Level
+}
+
+func (c levelCore) Enabled(level Level) bool {
+	return c.level.Enabled(level) || c.Core.Enabled(level)
+}
+
+func (c levelCore) With(fields []Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) || !c.level.Enabled(ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+	for level := ent.Level + 1; level <= zapcore.FatalLevel; level++ {
+		if !c.Core.Enabled(level) {
+			continue
+		}
+		raised := ent
+		raised.Level = level
+		if checked := c.Core.Check(raised, ce); checked != nil {
+			checked.Entry.Level = ent.Level
+			return checked
+		}
+		return ce
+	}
+	return ce
+}
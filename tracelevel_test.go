@@ -0,0 +1,71 @@
+package log
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTraceDebug(t *testing.T) {
+	TraceDebug = true
+	defer func() { TraceDebug = false }()
+
+	core, logs := observer.New(InfoLevel)
+	logger := NewLogger(zap.New(core))
+	tracer := mocktracer.New()
+
+	sampled := tracer.StartSpan("sampled")
+	For(opentracing.ContextWithSpan(context.Background(), sampled), logger).Debug("sampled")
+
+	unsampled := tracer.StartSpan("unsampled")
+	sc := unsampled.Context().(mocktracer.MockSpanContext)
+	sc.Sampled = false
+	unsampled = tracer.StartSpan("unsampled", opentracing.ChildOf(sc))
+	For(opentracing.ContextWithSpan(context.Background(), unsampled), logger).Debug("unsampled")
+
+	unsampled.SetBaggageItem("debug", "true")
+	For(opentracing.ContextWithSpan(context.Background(), unsampled), logger).Debug("flagged")
+
+	tc := TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Flags: 1}
+	For(ContextWithTraceContext(context.Background(), tc), logger).Debug("traceparent")
+
+	logger.Debug("global")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	if len(messages) != 3 || messages[0] != "sampled" || messages[1] != "flagged" || messages[2] != "traceparent" {
+		t.Error(messages)
+	}
+}
+
+func TestTraceDebugTee(t *testing.T) {
+	TraceDebug = true
+	defer func() { TraceDebug = false }()
+
+	infoCore, infoLogs := observer.New(InfoLevel)
+	errorCore, errorLogs := observer.New(ErrorLevel)
+	sampled := zapcore.NewSamplerWithOptions(infoCore, time.Minute, 1, 100)
+	logger := NewLogger(zap.New(zapcore.NewTee(sampled, errorCore)))
+
+	span := mocktracer.New().StartSpan("sampled")
+	spanLogger := For(opentracing.ContextWithSpan(context.Background(), span), logger)
+	spanLogger.Debug("debug")
+	spanLogger.Debug("debug")
+	spanLogger.Error("error")
+
+	entries := infoLogs.All()
+	if len(entries) != 2 || entries[0].Message != "debug" || entries[0].Level != DebugLevel {
+		t.Error(entries)
+	}
+	if entries := errorLogs.All(); len(entries) != 1 || entries[0].Message != "error" {
+		t.Error(entries)
+	}
+}
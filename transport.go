@@ -0,0 +1,324 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// DefaultBodyContentTypes are the content types of the captured bodies by
+// default, a type which ends with "/" matches all of its subtypes.
+var DefaultBodyContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/x-www-form-urlencoded",
+}
+
+// DefaultRedactedHeaders are the headers whose values are redacted by
+// default.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// TransportConfig configures the RoundTripper of NewTransport.
+type TransportConfig struct {
+	// RequestIDHeader is the header which propagates the request id of the
+	// context, default is RequestIDHeader.
+	RequestIDHeader string
+
+	// MaxBodySize is the max size of the captured request and response
+	// bodies, the bodies aren't captured if it is 0.
+	MaxBodySize int
+
+	// BodyContentTypes are the content types of the captured bodies, default
+	// is DefaultBodyContentTypes.
+	BodyContentTypes []string
+
+	// Headers logs the request and response headers.
+	Headers bool
+
+	// RedactedHeaders are the headers whose values are redacted, default is
+	// DefaultRedactedHeaders.
+	RedactedHeaders []string
+
+	// StatusLevels are the levels of the entries by the status class, default
+	// is DefaultStatusLevels. The failed calls are logged at ErrorLevel.
+	StatusLevels map[int]Level
+
+	// SpanLevel is the minimum level of the entries echoed into the span of
+	// the call, default is DefaultSpanLevel.
+	SpanLevel *Level
+
+	// Message is the message of the entries, default is "http call".
+	Message string
+}
+
+// NewTransport returns a http.RoundTripper which logs the outbound calls of
+// next with the Logger in the context of the requests. The entries have the
+// method, the url without credentials, the status, the latency and the retries
+// of ContextWithAttempts, and optionally the bodies and headers.
+//
+// If the response body is captured, the entry is written when the body is
+// read to the end or closed, so the callers must close it like always.
+//
+// The request id and the trace of the context are injected into the request
+// headers. If the context has an opentracing span, a child span is started
+// for the call and the entries are echoed into it.
+func NewTransport(next http.RoundTripper, cfg TransportConfig) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = RequestIDHeader
+	}
+	if cfg.BodyContentTypes == nil {
+		cfg.BodyContentTypes = DefaultBodyContentTypes
+	}
+	if cfg.RedactedHeaders == nil {
+		cfg.RedactedHeaders = DefaultRedactedHeaders
+	}
+	if cfg.StatusLevels == nil {
+		cfg.StatusLevels = DefaultStatusLevels
+	}
+	if cfg.SpanLevel == nil {
+		level := DefaultSpanLevel
+		cfg.SpanLevel = &level
+	}
+	if cfg.Message == "" {
+		cfg.Message = "http call"
+	}
+	redacted := make(map[string]struct{}, len(cfg.RedactedHeaders))
+	for _, key := range cfg.RedactedHeaders {
+		redacted[http.CanonicalHeaderKey(key)] = struct{}{}
+	}
+	return &transport{next: next, cfg: cfg, redacted: redacted}
+}
+
+type transport struct {
+	next     http.RoundTripper
+	cfg      TransportConfig
+	redacted map[string]struct{}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx := req.Context()
+	logger := LoggerOrEmptyFromContext(ctx)
+	req = req.Clone(ctx)
+
+	if id := RequestIDFromContext(ctx); id != "" && req.Header.Get(t.cfg.RequestIDHeader) == "" {
+		req.Header.Set(t.cfg.RequestIDHeader, id)
+	}
+
+	var span opentracing.Span
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		tracer := parent.Tracer()
+		span = tracer.StartSpan("HTTP "+req.Method, opentracing.ChildOf(parent.Context()), ext.SpanKindRPCClient)
+		ext.HTTPMethod.Set(span, req.Method)
+		ext.HTTPUrl.Set(span, redactURL(req))
+		tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+		logger = Span(logger, span, *t.cfg.SpanLevel)
+	} else if otelSpan := OTelSpanFromContext(ctx); otelSpan != nil {
+		logger = OTelSpan(logger, otelSpan, *t.cfg.SpanLevel)
+	} else if tc, ok := TraceContextFromContext(ctx); ok {
+		InjectTraceContext(req.Header, tc)
+	}
+
+	fields := []Field{String("method", req.Method), String("url", redactURL(req))}
+	if attempts, ok := ctx.Value(activeAttemptsKey).(*int32); ok {
+		if retries := atomic.AddInt32(attempts, 1) - 1; retries > 0 {
+			fields = append(fields, Int32("retries", retries))
+		}
+	}
+
+	var requestBody *captureReader
+	if t.cfg.MaxBodySize > 0 && req.Body != nil && req.Body != http.NoBody &&
+		t.captured(req.Header.Get("Content-Type")) {
+		requestBody = &captureReader{ReadCloser: req.Body, max: t.cfg.MaxBodySize}
+		req.Body = requestBody
+	}
+	if t.cfg.Headers {
+		fields = append(fields, Any("request_headers", t.redact(req.Header)))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	fields = append(fields, Duration("latency", time.Since(start)))
+	if requestBody != nil {
+		// the request body may be still read by next, such as the
+		// http.Transport which writes it concurrently
+		fields = append(fields, String("request_body", requestBody.String()))
+	}
+
+	logger = logger.AddCallerSkip(1)
+	if err != nil {
+		if span != nil {
+			ext.Error.Set(span, true)
+			span.Finish()
+		}
+		logger.Error(t.cfg.Message, append(fields, Error(err))...)
+		return nil, err
+	}
+
+	fields = append(fields, Int("status", resp.StatusCode))
+	if t.cfg.Headers {
+		fields = append(fields, Any("response_headers", t.redact(resp.Header)))
+	}
+
+	finish := func(fields []Field) {
+		if span != nil {
+			ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+			if resp.StatusCode >= 500 {
+				ext.Error.Set(span, true)
+			}
+			span.Finish()
+		}
+
+		level, ok := t.cfg.StatusLevels[resp.StatusCode/100]
+		if !ok {
+			level = InfoLevel
+		}
+		LogAt(logger, level, t.cfg.Message, fields...)
+	}
+
+	if t.cfg.MaxBodySize > 0 && resp.Body != nil && resp.Body != http.NoBody &&
+		t.captured(resp.Header.Get("Content-Type")) {
+		// the body is captured while it is read, so that the streaming
+		// responses aren't blocked
+		resp.Body = &loggedBody{
+			captureReader: &captureReader{ReadCloser: resp.Body, max: t.cfg.MaxBodySize},
+			done: func(body string, err error) {
+				fields = append(fields, String("response_body", body))
+				if err != nil {
+					fields = append(fields, NamedError("response_body_error", err))
+				}
+				finish(fields)
+			},
+		}
+		return resp, nil
+	}
+	finish(fields)
+	return resp, nil
+}
+
+// captured reports whether the bodies of the content type are captured.
+func (t *transport) captured(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range t.cfg.BodyContentTypes {
+		if mediaType == accepted ||
+			(strings.HasSuffix(accepted, "/") && strings.HasPrefix(mediaType, accepted)) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns the headers whose redacted values are replaced by "***".
+func (t *transport) redact(header http.Header) map[string]string {
+	values := make(map[string]string, len(header))
+	for key, value := range header {
+		if _, ok := t.redacted[key]; ok {
+			values[key] = "***"
+		} else {
+			values[key] = strings.Join(value, ", ")
+		}
+	}
+	return values
+}
+
+// redactURL returns the url of the request without the credentials.
+func redactURL(req *http.Request) string {
+	if req.URL.User == nil {
+		return req.URL.String()
+	}
+	u := *req.URL
+	u.User = nil
+	return u.String()
+}
+
+// captureReader captures the first max bytes which are read, it is safe to
+// read the captured bytes while reading.
+type captureReader struct {
+	io.ReadCloser
+	max int
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.mu.Lock()
+	if remain := r.max - r.buf.Len(); remain > 0 && n > 0 {
+		if remain > n {
+			remain = n
+		}
+		r.buf.Write(p[:remain])
+	}
+	r.mu.Unlock()
+	return n, err
+}
+
+// String returns the captured bytes.
+func (r *captureReader) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.String()
+}
+
+// loggedBody captures the response body while it is read, and calls done
+// once when it is read to the end, fails or is closed.
+type loggedBody struct {
+	*captureReader
+	once sync.Once
+	done func(body string, err error)
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.captureReader.Read(p)
+	if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *loggedBody) Close() error {
+	err := b.captureReader.Close()
+	b.finish(nil)
+	return err
+}
+
+func (b *loggedBody) finish(err error) {
+	b.once.Do(func() {
+		if err == io.EOF {
+			err = nil
+		}
+		b.done(b.String(), err)
+	})
+}
+
+type attemptsKey struct{}
+
+var activeAttemptsKey = attemptsKey{}
+
+// ContextWithAttempts returns a new `context.Context` that counts the calls
+// of the requests with it, so that the RoundTripper of NewTransport logs the
+// retries of a call which is retried with the same context.
+func ContextWithAttempts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, activeAttemptsKey, new(int32))
+}
@@ -0,0 +1,173 @@
+package log
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(RequestIDHeader) != "abc" || r.Header.Get("Mockpfx-Ids-Traceid") == "" {
+			t.Error(r.Header)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=1")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	core, logs := observer.New(DebugLevel)
+	span := mocktracer.New().StartSpan("parent")
+	ctx := ContextWithLogger(context.Background(), NewLogger(zap.New(core)))
+	ctx = ContextWithRequestID(ctx, "abc")
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	ctx = ContextWithAttempts(ctx)
+
+	client := &http.Client{Transport: NewTransport(nil, TransportConfig{MaxBodySize: 5, Headers: true})}
+	for _, path := range []string{"/users", "/missing"} {
+		req, _ := http.NewRequest("POST", strings.Replace(server.URL, "http://", "http://user:pass@", 1)+path,
+			strings.NewReader(`{"a":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "secret")
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != `{"a":1}` {
+			t.Error(string(body))
+		}
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	fields := entries[0].ContextMap()
+	if entries[0].Level != InfoLevel || fields["method"] != "POST" || strings.Contains(fields["url"].(string), "pass") ||
+		fields["status"] != int64(200) || fields["request_body"] != `{"a":` || fields["response_body"] != `{"a":` ||
+		fields["request_headers"].(map[string]string)["Authorization"] != "***" ||
+		fields["response_headers"].(map[string]string)["Set-Cookie"] != "***" {
+		t.Error(fields)
+	}
+	if _, ok := fields["retries"]; ok {
+		t.Error(fields)
+	}
+	if fields := entries[1].ContextMap(); entries[1].Level != WarnLevel || fields["retries"] != int32(1) {
+		t.Error(fields)
+	}
+	if spans := span.Tracer().(*mocktracer.MockTracer).FinishedSpans(); len(spans) != 2 {
+		t.Error(spans)
+	}
+}
+
+func TestTransportSpan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	core, logs := observer.New(DebugLevel)
+	// the debug entries are echoed with the default span level
+	client := &http.Client{Transport: NewTransport(nil, TransportConfig{StatusLevels: map[int]Level{2: DebugLevel}})}
+	handler := NewHTTPMiddleware(NewLogger(zap.New(core)), HTTPConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req.WithContext(r.Context()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+
+	spanIDs := map[string]string{}
+	for _, span := range tracer.FinishedSpans() {
+		spanIDs[span.OperationName] = strconv.Itoa(span.SpanContext.SpanID)
+		if records := span.Logs(); len(records) != 1 {
+			t.Error(span.OperationName, records)
+		}
+	}
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatal(entries)
+	}
+	for idx, name := range []string{"HTTP GET", "HTTP GET /users"} {
+		fields := entries[idx].ContextMap()
+		if len(entries[idx].Context) != len(fields) || fields["span_id"] != spanIDs[name] {
+			t.Error(entries[idx].Message, entries[idx].Context, spanIDs)
+		}
+	}
+}
+
+func TestTransportRequestBodyUnread(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, TransportConfig{MaxBodySize: 1 << 20})}
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest("POST", server.URL, strings.NewReader(strings.Repeat("a", 1<<20)))
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestTransportStreamingResponse(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	core, logs := observer.New(DebugLevel)
+	ctx := ContextWithLogger(context.Background(), NewLogger(zap.New(core)))
+	client := &http.Client{Transport: NewTransport(nil, TransportConfig{MaxBodySize: 100})}
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := make([]byte, 9)
+	if _, err := io.ReadFull(resp.Body, event); err != nil || string(event) != "data: 1\n\n" {
+		t.Fatal(string(event), err)
+	}
+	if logs.Len() != 0 {
+		t.Error(logs.AllUntimed())
+	}
+	resp.Body.Close()
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].ContextMap()["response_body"] != "data: 1\n\n" {
+		t.Error(entries)
+	}
+}
@@ -0,0 +1,217 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// The headers of https://www.w3.org/TR/trace-context/ and
+// https://www.w3.org/TR/baggage/
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+	BaggageHeader     = "baggage"
+)
+
+// BaggageFields is the allow-list of the baggage members of the W3C, the
+// opentracing and the OpenTelemetry baggage which are added as fields to the
+// context-aware loggers and the slog handler, the field names are the keys of
+// the members.
+var BaggageFields []string
+
+var errInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceContext is the W3C trace context and baggage of a request, it allows
+// correlating the entries of the services which don't use a tracer.
+type TraceContext struct {
+	TraceID    string
+	SpanID     string
+	Flags      byte
+	TraceState string
+	Baggage    map[string]string
+}
+
+// NewTraceContext returns a TraceContext with a random trace id and span id.
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8)}
+}
+
+func randomHex(n int) string {
+	bs := make([]byte, n)
+	rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
+
+// IsValid reports whether the trace id and the span id are valid.
+func (tc TraceContext) IsValid() bool {
+	return isHexID(tc.TraceID, 32) && isHexID(tc.SpanID, 16)
+}
+
+// Sampled reports whether the sampled flag is set.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&0x01 != 0
+}
+
+// TraceParent returns the value of the traceparent header.
+func (tc TraceContext) TraceParent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// ParseTraceParent parses the value of the traceparent header.
+func ParseTraceParent(s string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, errInvalidTraceParent
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return TraceContext{}, errInvalidTraceParent
+	}
+	tc := TraceContext{TraceID: parts[1], SpanID: parts[2], Flags: flags[0]}
+	if !tc.IsValid() {
+		return TraceContext{}, errInvalidTraceParent
+	}
+	return tc, nil
+}
+
+func isHexID(s string, length int) bool {
+	if len(s) != length || strings.Trim(s, "0") == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseBaggage parses the value of the baggage header, the properties of the
+// members are ignored.
+func ParseBaggage(s string) map[string]string {
+	var baggage map[string]string
+	for _, member := range strings.Split(s, ",") {
+		if idx := strings.IndexByte(member, ';'); idx >= 0 {
+			member = member[:idx]
+		}
+		idx := strings.IndexByte(member, '=')
+		if idx <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(member[:idx])
+		value, err := url.PathUnescape(strings.TrimSpace(member[idx+1:]))
+		if key == "" || err != nil {
+			continue
+		}
+		if baggage == nil {
+			baggage = map[string]string{}
+		}
+		baggage[key] = value
+	}
+	return baggage
+}
+
+// FormatBaggage returns the value of the baggage header.
+func FormatBaggage(baggage map[string]string) string {
+	keys := make([]string, 0, len(baggage))
+	for key := range baggage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(url.PathEscape(baggage[key]))
+	}
+	return sb.String()
+}
+
+// ExtractTraceContext reads the traceparent, tracestate and baggage headers,
+// ok is false if there isn't a valid traceparent.
+func ExtractTraceContext(header http.Header) (tc TraceContext, ok bool) {
+	tc, err := ParseTraceParent(header.Get(TraceParentHeader))
+	if err != nil {
+		return TraceContext{}, false
+	}
+	tc.TraceState = strings.Join(header.Values(TraceStateHeader), ",")
+	tc.Baggage = ParseBaggage(strings.Join(header.Values(BaggageHeader), ","))
+	return tc, true
+}
+
+// InjectTraceContext writes the traceparent, tracestate and baggage headers.
+func InjectTraceContext(header http.Header, tc TraceContext) {
+	if !tc.IsValid() {
+		return
+	}
+	header.Set(TraceParentHeader, tc.TraceParent())
+	if tc.TraceState != "" {
+		header.Set(TraceStateHeader, tc.TraceState)
+	}
+	if len(tc.Baggage) > 0 {
+		header.Set(BaggageHeader, FormatBaggage(tc.Baggage))
+	}
+}
+
+type traceContextKey struct{}
+
+var activeTraceContextKey = traceContextKey{}
+
+// ContextWithTraceContext returns a new `context.Context` that holds the tc.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, activeTraceContextKey, tc)
+}
+
+// TraceContextFromContext returns the TraceContext previously associated
+// with `ctx`.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(activeTraceContextKey).(TraceContext)
+	return tc, ok
+}
+
+// ExtractHTTP returns a new `context.Context` that holds the trace context
+// of the headers, or ctx if there isn't one.
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	if tc, ok := ExtractTraceContext(header); ok {
+		return ContextWithTraceContext(ctx, tc)
+	}
+	return ctx
+}
+
+// InjectHTTP writes the trace context in the ctx to the headers of an
+// outgoing request.
+func InjectHTTP(ctx context.Context, header http.Header) {
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		InjectTraceContext(header, tc)
+	}
+}
+
+// Fields returns the trace id, span id and the allowed baggage fields.
+func (tc TraceContext) Fields() []Field {
+	fields := make([]Field, 0, 2+len(BaggageFields))
+	if tc.IsValid() {
+		if TraceIDKey != "" {
+			fields = append(fields, String(TraceIDKey, tc.TraceID))
+		}
+		if SpanIDKey != "" {
+			fields = append(fields, String(SpanIDKey, tc.SpanID))
+		}
+	}
+	for _, key := range BaggageFields {
+		if value, ok := tc.Baggage[key]; ok {
+			fields = append(fields, String(key, value))
+		}
+	}
+	return fields
+}
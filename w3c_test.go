@@ -0,0 +1,57 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTraceContextPropagation(t *testing.T) {
+	header := http.Header{}
+	header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("Tracestate", "congo=t61rcWkgMzE")
+	header.Set("Baggage", "tenant=acme, user=a%20b;prop=1,invalid")
+
+	ctx := ExtractHTTP(context.Background(), header)
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok || !tc.Sampled() || tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID != "00f067aa0ba902b7" {
+		t.Fatalf("%#v", tc)
+	}
+	if tc.Baggage["tenant"] != "acme" || tc.Baggage["user"] != "a b" || len(tc.Baggage) != 2 {
+		t.Errorf("%#v", tc.Baggage)
+	}
+
+	out := http.Header{}
+	InjectHTTP(ctx, out)
+	if out.Get("traceparent") != header.Get("traceparent") ||
+		out.Get("tracestate") != header.Get("tracestate") ||
+		out.Get("baggage") != "tenant=acme,user=a%20b" {
+		t.Error(out)
+	}
+
+	BaggageFields = []string{"tenant"}
+	defer func() { BaggageFields = nil }()
+
+	core, logs := observer.New(DebugLevel)
+	For(ctx, NewLogger(zap.New(core))).Info("a")
+	fields := logs.All()[0].ContextMap()
+	if len(fields) != 3 || fields["trace_id"] != tc.TraceID || fields["span_id"] != tc.SpanID || fields["tenant"] != "acme" {
+		t.Error(fields)
+	}
+
+	for _, s := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceParent(s); err == nil {
+			t.Error("excepted error for", s)
+		}
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-func New(out io.Writer) Logger {
+func New(out io.Writer, opts ...Option) Logger {
 	outSink := zapcore.Lock(zapcore.AddSync(out))
 
 	cfg := zap.NewProductionConfig()
@@ -19,5 +19,5 @@ func New(out io.Writer) Logger {
 		),
 		zap.ErrorOutput(outSink),
 	)
-	return NewLogger(logger)
+	return NewLogger(logger, opts...)
 }
\ No newline at end of file